@@ -23,24 +23,32 @@ import (
 )
 
 type Application struct {
-	config        Config
-	store         store.Storage
-	cacheStorage  cache.Storage
-	logger        *zap.SugaredLogger
-	mailer        mailer.Client
-	authenticator auth.Authenticator
-	rateLimiter   ratelimiter.Limiter
+	config          Config
+	store           store.Storage
+	cacheStorage    cache.Storage
+	logger          *zap.SugaredLogger
+	mailer          mailer.Client
+	authenticator   auth.Authenticator
+	rateLimiter     ratelimiter.Limiter
+	authRateLimiter ratelimiter.Limiter
 }
 
 type Config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	apiUrl      string
-	mail        mailConfig
-	auth        authConfig
-	redisCfg    redisConfig
-	rateLimiter ratelimiter.Config
+	addr            string
+	db              dbConfig
+	env             string
+	apiUrl          string
+	mail            mailConfig
+	auth            authConfig
+	redisCfg        redisConfig
+	rateLimiter     ratelimiter.Config
+	authRateLimiter ratelimiter.Config
+	duplicates      duplicatesConfig
+}
+
+type duplicatesConfig struct {
+	enabled  bool
+	interval time.Duration
 }
 
 type redisConfig struct {
@@ -61,15 +69,17 @@ type basicAuthConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
 }
 
 type mailConfig struct {
 	sendGrid  sendGridConfig
 	fromEmail string
 	exp       time.Duration
+	resetExp  time.Duration
 }
 
 type sendGridConfig struct {
@@ -101,7 +111,7 @@ func (app *Application) mount() *fiber.App {
 	router.Use(logger.New(logger.Config{
 		Format: "[${ip}]:${port} ${status} - ${method} ${path}\n",
 	}))
-	router.Use(app.RateLimiterMiddleware())
+	router.Use(app.RateLimiterMiddleware(app.rateLimiter))
 
 	v1 := router.Group("/v1")
 
@@ -109,12 +119,30 @@ func (app *Application) mount() *fiber.App {
 	v1.Get("/health", app.healthCheckHandler)
 
 	auth := v1.Group("/authentication")
-	auth.Post("/register", app.registerUserHandler)
-	auth.Post("/login", app.BasicAuthMiddleware(), app.createTokenHandler)
+	auth.Post("/register", app.RateLimiterMiddleware(app.authRateLimiter), app.registerUserHandler)
+	auth.Post("/login", app.RateLimiterMiddleware(app.authRateLimiter), app.BasicAuthMiddleware(), app.createTokenHandler)
+	auth.Post("/refresh", app.RateLimiterMiddleware(app.authRateLimiter), app.refreshTokenHandler)
+	auth.Post("/logout", app.AuthTokenMiddleware(), app.logoutHandler)
+	auth.Post("/password/forgot", app.RateLimiterMiddleware(app.authRateLimiter), app.forgotPasswordHandler)
+	auth.Post("/password/reset", app.RateLimiterMiddleware(app.authRateLimiter), app.resetPasswordHandler)
 
 	users := v1.Group("/users")
 	users.Put("/activate/:token", app.activateUserHandler)
 	users.Get("/self", app.AuthTokenMiddleware(), app.getSelfHandler)
+	users.Get("/self/privacy", app.AuthTokenMiddleware(), app.getSelfPrivacyHandler)
+	users.Patch("/self/privacy", app.AuthTokenMiddleware(), app.updateSelfPrivacyHandler)
+	users.Get("/self/records", app.AuthTokenMiddleware(), app.getSelfRecordsHandler)
+	users.Get("/self/records/:exerciseID", app.AuthTokenMiddleware(), app.getSelfExerciseHistoryHandler)
+	users.Get("/self/nutrition/profile", app.AuthTokenMiddleware(), app.getSelfNutritionProfileHandler)
+	users.Put("/self/nutrition/profile", app.AuthTokenMiddleware(), app.upsertSelfNutritionProfileHandler)
+	users.Get("/self/nutrition/targets", app.AuthTokenMiddleware(), app.getSelfNutritionTargetsHandler)
+	users.Post("/self/nutrition/targets", app.AuthTokenMiddleware(), app.createSelfNutritionTargetsHandler)
+	users.Get("/self/measurements", app.AuthTokenMiddleware(), app.getSelfMeasurementsHandler)
+	users.Post("/self/measurements", app.AuthTokenMiddleware(), app.createSelfMeasurementHandler)
+	users.Get("/self/measurements/trend", app.AuthTokenMiddleware(), app.getSelfMeasurementTrendHandler)
+	users.Delete("/self/measurements/:id", app.AuthTokenMiddleware(), app.measurementsContextMiddleware(), app.deleteSelfMeasurementHandler)
+	users.Get("/self/foods", app.AuthTokenMiddleware(), app.getSelfFoodsHandler)
+	users.Get("/self/favourites", app.AuthTokenMiddleware(), app.getSelfFavouriteFoodsHandler)
 	users.Get("/feed", app.AuthTokenMiddleware(), app.getUserFeedHandler)
 
 	user := users.Group("/:id", app.AuthTokenMiddleware())
@@ -124,12 +152,71 @@ func (app *Application) mount() *fiber.App {
 
 	v1.Post("/food", app.AuthTokenMiddleware(), app.createFoodHandler)
 	v1.Get("/food", app.AuthTokenMiddleware(), app.getAllFoodHandler)
-	v1.Get("/food/:id", app.AuthTokenMiddleware(), app.getFoodHandler)
+	v1.Get("/food/suggestions", app.AuthTokenMiddleware(), app.getFoodSuggestionsHandler)
+	v1.Get("/food/barcode/:code", app.AuthTokenMiddleware(), app.getFoodByBarcodeHandler)
+	v1.Get("/food/:id", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.getFoodHandler)
+	v1.Patch("/food/:id", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.updateFoodHandler)
+	v1.Get("/food/:id/revisions", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.getFoodRevisionsHandler)
+	v1.Get("/food/:id/portions", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.getFoodPortionsHandler)
+	v1.Post("/food/:id/portions", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.createFoodPortionHandler)
+	v1.Delete("/food/:id/portions/:portionID", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.deleteFoodPortionHandler)
+	v1.Put("/food/:id/favourite", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.favouriteFoodHandler)
+	v1.Put("/food/:id/unfavourite", app.AuthTokenMiddleware(), app.foodsContextMiddleware(), app.unfavouriteFoodHandler)
 
 	meals := v1.Group("/meals", app.AuthTokenMiddleware())
+	meals.Get("/", app.getMealDiaryHandler)
 	meals.Post("/", app.createMealEntryHandler)
-	meals.Patch("/:id", app.updateMealEntryHandler)
-	meals.Delete("/:id", app.deleteMealEntryHandler)
+	meals.Post("/copy", app.copyMealsHandler)
+	meals.Get("/templates", app.getMealTemplatesHandler)
+	meals.Post("/templates", app.createMealTemplateHandler)
+	meals.Delete("/templates/:id", app.mealTemplatesContextMiddleware(), app.deleteMealTemplateHandler)
+	meals.Post("/templates/:id/apply", app.mealTemplatesContextMiddleware(), app.applyMealTemplateHandler)
+	meals.Patch("/:id", app.mealEntriesContextMiddleware(), app.updateMealEntryHandler)
+	meals.Put("/:id/share", app.mealsContextMiddleware(), app.shareMealHandler)
+	meals.Put("/:id/unshare", app.mealsContextMiddleware(), app.unshareMealHandler)
+	meals.Delete("/:id", app.mealEntriesContextMiddleware(), app.deleteMealEntryHandler)
+
+	recipes := v1.Group("/recipes", app.AuthTokenMiddleware())
+	recipes.Get("/", app.getRecipesHandler)
+	recipes.Post("/", app.createRecipeHandler)
+	recipes.Get("/:id", app.recipesContextMiddleware(), app.getRecipeHandler)
+	recipes.Put("/:id", app.recipesContextMiddleware(), app.updateRecipeHandler)
+	recipes.Delete("/:id", app.recipesContextMiddleware(), app.deleteRecipeHandler)
+
+	admin := v1.Group("/admin", app.AuthTokenMiddleware(), app.RequireRole(store.RoleLevelAdmin))
+	admin.Get("/foods/pending", app.getPendingFoodsHandler)
+	admin.Get("/foods/duplicates", app.getDuplicateFoodsHandler)
+	admin.Post("/foods/duplicates/detect", app.detectDuplicateFoodsHandler)
+	admin.Patch("/foods/:id", app.moderatedFoodsContextMiddleware(), app.moderatorUpdateFoodHandler)
+	admin.Post("/foods/:id/approve", app.moderatedFoodsContextMiddleware(), app.approveFoodHandler)
+	admin.Post("/foods/:id/reject", app.moderatedFoodsContextMiddleware(), app.rejectFoodHandler)
+	admin.Get("/foods/:id/moderation", app.moderatedFoodsContextMiddleware(), app.getFoodModerationHistoryHandler)
+	admin.Post("/foods/:id/merge", app.moderatedFoodsContextMiddleware(), app.mergeFoodHandler)
+
+	v1.Get("/exercises", app.AuthTokenMiddleware(), app.getExercisesHandler)
+	v1.Post("/exercises", app.AuthTokenMiddleware(), app.createExerciseHandler)
+	v1.Get("/exercises/:id", app.AuthTokenMiddleware(), app.getExerciseHandler)
+
+	workouts := v1.Group("/workouts", app.AuthTokenMiddleware())
+	workouts.Get("/", app.getWorkoutsHandler)
+	workouts.Post("/", app.createWorkoutHandler)
+	workouts.Get("/:id", app.workoutsContextMiddleware(), app.getWorkoutHandler)
+	workouts.Patch("/:id", app.workoutsContextMiddleware(), app.updateWorkoutHandler)
+	workouts.Delete("/:id", app.workoutsContextMiddleware(), app.deleteWorkoutHandler)
+
+	routines := v1.Group("/routines", app.AuthTokenMiddleware())
+	routines.Get("/", app.getRoutinesHandler)
+	routines.Post("/", app.createRoutineHandler)
+	routines.Get("/:id", app.routinesContextMiddleware(), app.getRoutineHandler)
+	routines.Delete("/:id", app.routinesContextMiddleware(), app.deleteRoutineHandler)
+	routines.Post("/:id/start", app.routinesContextMiddleware(), app.startRoutineHandler)
+
+	programs := v1.Group("/programs", app.AuthTokenMiddleware())
+	programs.Post("/", app.RequireRole(store.RoleLevelSubscriber), app.createProgramHandler)
+	programs.Get("/:id", app.getProgramHandler)
+	programs.Post("/:id/enroll", app.enrollProgramHandler)
+	programs.Get("/:id/enrollment", app.getProgramEnrollmentHandler)
+	programs.Post("/:id/workouts", app.startProgramWorkoutHandler)
 
 	return router
 }
@@ -138,6 +225,14 @@ func (app *Application) run(router *fiber.App) error {
 	// Channel for shutdown errors
 	shutdown := make(chan error, 1)
 
+	// Background jobs stop once the server has
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if app.config.duplicates.enabled {
+		go app.detectDuplicateFoods(jobs, app.config.duplicates.interval)
+	}
+
 	// Graceful shutdown goroutine
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -105,6 +108,7 @@ func (app *Application) registerUserHandler(c *fiber.Ctx) error {
 		if err := app.store.Users.Delete(c.Context(), user.ID); err != nil {
 			app.logger.Errorw("error deleting user", "error", err)
 		}
+		app.invalidateUser(c, user.ID)
 
 		return app.internalServerError(c, err)
 	}
@@ -123,14 +127,25 @@ type CreateUserTokenPayload struct {
 	Password string `json:"password" validate:"required,min=8"`
 }
 
+type UserWithTokens struct {
+	*store.User
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates an access token and a refresh token for a user
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	store.User	"User logged in"
+//	@Success		201	{object}	UserWithTokens	"User logged in"
 //	@Failure		400	{object}	error
 //	@Failure		401	{object}	error
 //	@Failure		500	{object}	error
@@ -138,9 +153,241 @@ type CreateUserTokenPayload struct {
 func (app *Application) createTokenHandler(c *fiber.Ctx) error {
 	self := getSelfFromContext(c)
 
+	plainRefresh, refreshToken, err := app.newRefreshToken(self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.store.Tokens.CreateRefreshToken(c.Context(), refreshToken); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return app.tokensResponse(c, self, plainRefresh)
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and a rotated refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	UserWithTokens		"Tokens refreshed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *Application) refreshTokenHandler(c *fiber.Ctx) error {
+	var payload RefreshTokenPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	plainRefresh, next, err := app.newRefreshToken(uuid.Nil)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	err = app.store.Tokens.RotateRefreshToken(c.Context(), hashToken(payload.RefreshToken), next)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrTokenExpired):
+			return app.unauthorizedErrorResponse(c, err)
+		case errors.Is(err, store.ErrTokenReused):
+			app.logger.Warnw("refresh token reuse, session revoked", "error", err)
+			return app.unauthorizedErrorResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	user, err := app.store.Users.GetByID(c.Context(), next.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.unauthorizedErrorResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.tokensResponse(c, user, plainRefresh)
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the current access token and, when given, the session of the refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayload	false	"Refresh token"
+//	@Success		204		{string}	string				"Logged out"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/authentication/logout [post]
+func (app *Application) logoutHandler(c *fiber.Ctx) error {
+	if len(c.Body()) > 0 {
+		var payload RefreshTokenPayload
+		if err := readJSON(c, &payload); err != nil {
+			return app.badRequestResponse(c, err)
+		}
+
+		if err := Validate.Struct(payload); err != nil {
+			return app.badRequestResponse(c, err)
+		}
+
+		err := app.store.Tokens.RevokeRefreshToken(c.Context(), hashToken(payload.RefreshToken))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	claims := getClaimsFromContext(c)
+	if err := app.revokeAccessToken(c, claims); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	c.ClearCookie("jwt")
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Requests a password reset
+//	@Description	Emails a single use password reset link to the user. The response is the same whether or not the email belongs to an account
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset requested"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *Application) forgotPasswordHandler(c *fiber.Ctx) error {
+	var payload ForgotPasswordPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	user, err := app.store.Users.GetByEmail(c.Context(), payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			// don't reveal which emails have an account
+			return app.jsonResponse(c, http.StatusAccepted, nil)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	plainToken := uuid.New().String()
+
+	if err := app.store.Users.CreatePasswordReset(c.Context(), hashToken(plainToken), app.config.mail.resetExp, user.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	isProdEnv := app.config.env == "production"
+
+	// TODO: Change this to the frontend app url
+	resetUrl := fmt.Sprintf("%s/v1/authentication/password/reset?token=%s", app.config.apiUrl, plainToken)
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  resetUrl,
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	// a failed send answers like any other request, so it doesn't reveal the account exists
+	status, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		app.logger.Errorw("error sending password reset email", "error", err)
+	} else {
+		app.logger.Infow("Email sent", "status code", status)
+	}
+
+	if err := app.jsonResponse(c, http.StatusAccepted, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Resets a password
+//	@Description	Sets a new password using a token from a password reset email and signs the user out of every existing session
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *Application) resetPasswordHandler(c *fiber.Ctx) error {
+	var payload ResetPasswordPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	userID, err := app.store.Users.ResetPassword(c.Context(), payload.Token, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	app.invalidateUser(c, userID)
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+func (app *Application) tokensResponse(c *fiber.Ctx, user *store.User, refreshToken string) error {
 	// generate a token for the user & add claims
 	claims := jwt.MapClaims{
-		"sub": self.ID,
+		"sub": user.ID,
+		"jti": uuid.New().String(),
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
@@ -154,23 +401,51 @@ func (app *Application) createTokenHandler(c *fiber.Ctx) error {
 	}
 
 	// set the token in the cookie
-	setTokenCookie(c, token)
+	setTokenCookie(c, token, app.config.auth.token.exp)
 
-	if err := app.jsonResponse(c, http.StatusCreated, self); err != nil {
+	res := UserWithTokens{
+		User:         user,
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(app.config.auth.token.exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, res); err != nil {
 		return app.internalServerError(c, err)
 	}
 
 	return nil
 }
 
-func setTokenCookie(c *fiber.Ctx, token string) {
+// newRefreshToken returns a random refresh token and its stored, hashed form.
+func (app *Application) newRefreshToken(userID uuid.UUID) (string, *store.RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	plainToken := base64.RawURLEncoding.EncodeToString(b)
+
+	return plainToken, &store.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(plainToken),
+		Expiry:    time.Now().Add(app.config.auth.token.refreshExp),
+	}, nil
+}
+
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
+
+func setTokenCookie(c *fiber.Ctx, token string, exp time.Duration) {
 	cookie := new(fiber.Cookie)
 	cookie.Name = "jwt"
 	cookie.Value = token
-	cookie.Expires = time.Now().Add(time.Hour * 24 * 3) // Set expiration time
-	cookie.HTTPOnly = true                              // Makes the cookie inaccessible to client-side scripts
-	cookie.Secure = true                                // Only send over HTTPS
-	cookie.SameSite = "Lax"                             // Provides some CSRF protection
+	cookie.Expires = time.Now().Add(exp) // Set expiration time
+	cookie.HTTPOnly = true               // Makes the cookie inaccessible to client-side scripts
+	cookie.Secure = true                 // Only send over HTTPS
+	cookie.SameSite = "Lax"              // Provides some CSRF protection
 
 	c.Cookie(cookie)
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type MergeFoodPayload struct {
+	IntoID string `json:"into_id" validate:"required,uuid"`
+}
+
+type DuplicateDetectionResult struct {
+	Pairs int `json:"pairs"`
+}
+
+// detectDuplicateFoods refreshes the duplicate candidates right away and then
+// every interval, until ctx is cancelled.
+func (app *Application) detectDuplicateFoods(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pairs, err := app.store.Foods.DetectDuplicates(ctx)
+		switch {
+		case errors.Is(err, store.ErrDetectionInProgress):
+			app.logger.Infow("duplicate food detection skipped, another instance is running it")
+		case err != nil:
+			app.logger.Errorw("duplicate food detection failed", "error", err)
+		default:
+			app.logger.Infow("duplicate food detection finished", "pairs", pairs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetDuplicateFoods godoc
+//
+//	@Summary		Fetches duplicate food candidates
+//	@Description	Lists clusters of public foods with similar names, brands and nutrition, as found by the last detection run, most similar first
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		store.DuplicateCluster
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/duplicates [get]
+func (app *Application) getDuplicateFoodsHandler(c *fiber.Ctx) error {
+	clusters, err := app.store.Foods.GetDuplicateClusters(c.Context())
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, clusters); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DetectDuplicateFoods godoc
+//
+//	@Summary		Runs duplicate detection
+//	@Description	Looks for duplicate foods now instead of waiting for the next scheduled run
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	DuplicateDetectionResult
+//	@Failure		403	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/duplicates/detect [post]
+func (app *Application) detectDuplicateFoodsHandler(c *fiber.Ctx) error {
+	pairs, err := app.store.Foods.DetectDuplicates(c.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDetectionInProgress):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, DuplicateDetectionResult{Pairs: pairs}); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// MergeFood godoc
+//
+//	@Summary		Merges a duplicate food
+//	@Description	Moves every meal entry, recipe ingredient, template entry and favourite of a food to another approved public food, its id keeps resolving to the surviving food. Entries already logged keep the merged food's values. The food's serving unit and portions have to convert to the surviving food's serving unit
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"ID of the food to merge away"
+//	@Param			payload	body		MergeFoodPayload	true	"Merge payload"
+//	@Success		200		{object}	store.Food
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/{id}/merge [post]
+func (app *Application) mergeFoodHandler(c *fiber.Ctx) error {
+	var payload MergeFoodPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getModeratedFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	into, err := app.store.Foods.GetByID(c.Context(), uuid.MustParse(payload.IntoID))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.badRequestResponse(c, store.ErrUnknownFood)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.store.Foods.Merge(c.Context(), food.ID, into.ID, self.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrMergeIntoSelf), errors.Is(err, store.ErrMergeTarget), errors.Is(err, store.ErrMergeUnits):
+			return app.badRequestResponse(c, err)
+		case errors.Is(err, store.ErrMergePortions):
+			return app.conflictResponse(c, err)
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	// reloaded for the barcode and density it may have taken over
+	merged, err := app.store.Foods.GetByID(c.Context(), into.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, merged); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
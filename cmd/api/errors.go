@@ -55,5 +55,5 @@ func (app *Application) rateLimitExceededResponse(c *fiber.Ctx, retryAfter strin
 
 	c.Set("Retry-After", retryAfter)
 
-	return writeJSONError(c, http.StatusTooManyRequests, "rate limit exceeded, retry after:"+retryAfter)
+	return writeJSONError(c, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter+"s")
 }
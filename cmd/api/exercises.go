@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type CreateExercisePayload struct {
+	Name         string   `json:"name" validate:"required,max=255"`
+	Description  string   `json:"description" validate:"max=1000"`
+	MuscleGroups []string `json:"muscle_groups" validate:"required,min=1,dive,required,max=50"`
+	Equipment    string   `json:"equipment" validate:"required,max=100"`
+	MovementType string   `json:"movement_type" validate:"required,oneof=compound isolation cardio mobility"`
+}
+
+// CreateExercise godoc
+//
+//	@Summary		Creates an exercise
+//	@Description	Adds a custom exercise to the catalog
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateExercisePayload	true	"Exercise payload"
+//	@Success		201		{object}	store.Exercise
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/exercises [post]
+func (app *Application) createExerciseHandler(c *fiber.Ctx) error {
+	var payload CreateExercisePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	exercise := store.Exercise{
+		Name:         payload.Name,
+		Description:  payload.Description,
+		MuscleGroups: payload.MuscleGroups,
+		Equipment:    payload.Equipment,
+		MovementType: payload.MovementType,
+		UserID:       &self.ID,
+	}
+
+	if err := app.store.Exercises.Create(c.Context(), &exercise); err != nil {
+		switch err {
+		case store.ErrConflict:
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, exercise); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetExercises godoc
+//
+//	@Summary		Fetches exercises
+//	@Description	Lists the exercise catalog
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			search			query		string	false	"Name search"
+//	@Param			muscle_group	query		string	false	"Muscle group"
+//	@Param			equipment		query		string	false	"Equipment"
+//	@Param			movement_type	query		string	false	"Movement type"
+//	@Success		200				{array}		store.Exercise
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/exercises [get]
+func (app *Application) getExercisesHandler(c *fiber.Ctx) error {
+	filter := store.ExerciseFilter{
+		Search:       c.Query("search"),
+		MuscleGroup:  c.Query("muscle_group"),
+		Equipment:    c.Query("equipment"),
+		MovementType: c.Query("movement_type"),
+	}
+
+	exercises, err := app.store.Exercises.List(c.Context(), filter)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, exercises); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetExercise godoc
+//
+//	@Summary		Fetches an exercise
+//	@Description	Fetches an exercise by ID
+//	@Tags			exercises
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Exercise ID"
+//	@Success		200	{object}	store.Exercise
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/exercises/{id} [get]
+func (app *Application) getExerciseHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	exercise, err := app.store.Exercises.GetByID(c.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, exercise); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
@@ -1,8 +1,132 @@
 package main
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
 
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type workoutActivityData struct {
+	Name      string  `json:"name"`
+	StartedAt string  `json:"started_at"`
+	EndedAt   *string `json:"ended_at"`
+	Sets      int     `json:"sets"`
+	Volume    float64 `json:"volume"`
+}
+
+type mealActivityData struct {
+	Name   string                `json:"name"`
+	Date   string                `json:"date"`
+	Foods  []string              `json:"foods"`
+	Totals store.NutritionTotals `json:"totals"`
+}
+
+// GetUserFeed godoc
+//
+//	@Summary		Fetches the user feed
+//	@Description	Fetches activity from the users the logged in user follows, newest first
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			type	query		string	false	"Comma separated activity types (workout, personal_record, meal)"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Success		200		{object}	store.FeedPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/feed [get]
 func (app *Application) getUserFeedHandler(c *fiber.Ctx) error {
-	// pagination, filters etc.
+	fq := store.PaginatedFeedQuery{
+		PaginatedQuery: store.PaginatedQuery{
+			Limit: 20,
+		},
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	feed, err := app.store.Activities.GetUserFeed(c.Context(), self.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, feed); err != nil {
+		return app.internalServerError(c, err)
+	}
+
 	return nil
 }
+
+// publishWorkoutActivity adds a finished workout, and any personal records set
+// in it, to the author's followers' feeds. Failures are logged rather than
+// failing the request since the workout itself has been saved.
+func (app *Application) publishWorkoutActivity(c *fiber.Ctx, workout *store.Workout) {
+	if workout.EndedAt == nil {
+		return
+	}
+
+	data := workoutActivityData{
+		Name:      workout.Name,
+		StartedAt: workout.StartedAt,
+		EndedAt:   workout.EndedAt,
+		Sets:      len(workout.Sets),
+	}
+	for _, set := range workout.Sets {
+		if set.Weight != nil && set.Reps != nil {
+			data.Volume += *set.Weight * float64(*set.Reps)
+		}
+	}
+
+	if err := app.publishActivity(c, workout.UserID, workout.ID, store.ActivityWorkout, data); err != nil {
+		app.logger.Errorw("error publishing workout activity", "workout", workout.ID, "error", err)
+		return
+	}
+
+	records, err := app.store.Records.GetWorkoutRecords(c.Context(), workout.UserID, workout.ID, store.Epley)
+	if err != nil {
+		app.logger.Errorw("error computing workout records", "workout", workout.ID, "error", err)
+		return
+	}
+
+	for _, record := range records {
+		if err := app.publishActivity(c, workout.UserID, workout.ID, store.ActivityPersonalRecord, record); err != nil {
+			app.logger.Errorw("error publishing record activity", "workout", workout.ID, "error", err)
+			return
+		}
+	}
+}
+
+func (app *Application) publishActivity(c *fiber.Ctx, userID, referenceID uuid.UUID, activityType string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	activity := store.Activity{
+		UserID:      userID,
+		Type:        activityType,
+		ReferenceID: referenceID,
+		Data:        raw,
+	}
+
+	return app.store.Activities.Create(c.Context(), &activity)
+}
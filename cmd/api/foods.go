@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -11,22 +12,126 @@ import (
 
 // TODO: Update validate struct tags
 type CreateFoodPayload struct {
-	Name        string  `json:"name" validate:"required,max=100"`
-	Description string  `json:"description" validate:"required,max=1000"`
-	Calories    int     `json:"calories" validate:"required"`
-	Protein     float64 `json:"protein" validate:"required"`
-	Carbs       float64 `json:"carbs" validate:"required"`
-	Fat         float64 `json:"fat" validate:"required"`
-	Brand       string  `json:"brand" validate:"max=100"`
-	ServingSize float64 `json:"serving_size" validate:"required"`
-	ServingUnit string  `json:"serving_unit" validate:"required"`
-	Verified    bool    `json:"verified"`
+	Name           string                `json:"name" validate:"required,max=100"`
+	Description    string                `json:"description" validate:"required,max=1000"`
+	Calories       int                   `json:"calories" validate:"required"`
+	Protein        float64               `json:"protein" validate:"required"`
+	Carbs          float64               `json:"carbs" validate:"required"`
+	Fat            float64               `json:"fat" validate:"required"`
+	Fiber          float64               `json:"fiber" validate:"gte=0"`
+	Brand          string                `json:"brand" validate:"max=100"`
+	ServingSize    float64               `json:"serving_size" validate:"required"`
+	ServingUnit    string                `json:"serving_unit" validate:"required"`
+	Density        float64               `json:"density" validate:"omitempty,gt=0"`
+	Barcode        string                `json:"barcode" validate:"omitempty,max=20"`
+	Visibility     string                `json:"visibility" validate:"omitempty,oneof=private public"`
+	Micronutrients MicronutrientsPayload `json:"micronutrients"`
+}
+
+type UpdateFoodPayload struct {
+	Name        *string  `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string  `json:"description" validate:"omitempty,max=1000"`
+	Calories    *int     `json:"calories" validate:"omitempty,gte=0"`
+	Protein     *float64 `json:"protein" validate:"omitempty,gte=0"`
+	Carbs       *float64 `json:"carbs" validate:"omitempty,gte=0"`
+	Fat         *float64 `json:"fat" validate:"omitempty,gte=0"`
+	Fiber       *float64 `json:"fiber" validate:"omitempty,gte=0"`
+	Brand       *string  `json:"brand" validate:"omitempty,max=100"`
+	ServingSize *float64 `json:"serving_size" validate:"omitempty,gt=0"`
+	ServingUnit *string  `json:"serving_unit" validate:"omitempty,min=1"`
+	// Density is in g/ml, 0 clears it.
+	Density *float64 `json:"density" validate:"omitempty,gte=0"`
+	// Micronutrients replaces all of the food's micronutrients when set.
+	Micronutrients *MicronutrientsPayload `json:"micronutrients"`
+}
+
+// MicronutrientsPayload mirrors store.Micronutrients, sugar and saturated fat
+// in grams, vitamins A and D in micrograms and the rest in milligrams. Values
+// left out are taken as zero.
+type MicronutrientsPayload struct {
+	Sugar        float64 `json:"sugar" validate:"gte=0"`
+	SaturatedFat float64 `json:"saturated_fat" validate:"gte=0"`
+	Sodium       float64 `json:"sodium" validate:"gte=0"`
+	Cholesterol  float64 `json:"cholesterol" validate:"gte=0"`
+	Potassium    float64 `json:"potassium" validate:"gte=0"`
+	VitaminA     float64 `json:"vitamin_a" validate:"gte=0"`
+	VitaminC     float64 `json:"vitamin_c" validate:"gte=0"`
+	VitaminD     float64 `json:"vitamin_d" validate:"gte=0"`
+}
+
+// apply returns a copy of the food with the fields set in the payload.
+func (payload UpdateFoodPayload) apply(food *store.Food) *store.Food {
+	updated := *food
+	if payload.Name != nil {
+		updated.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		updated.Description = *payload.Description
+	}
+	if payload.Calories != nil {
+		updated.Calories = *payload.Calories
+	}
+	if payload.Protein != nil {
+		updated.Protein = *payload.Protein
+	}
+	if payload.Carbs != nil {
+		updated.Carbs = *payload.Carbs
+	}
+	if payload.Fat != nil {
+		updated.Fat = *payload.Fat
+	}
+	if payload.Fiber != nil {
+		updated.Fiber = *payload.Fiber
+	}
+	if payload.Micronutrients != nil {
+		updated.Micronutrients = store.Micronutrients(*payload.Micronutrients)
+	}
+	if payload.Brand != nil {
+		updated.Brand = *payload.Brand
+	}
+	if payload.ServingSize != nil {
+		updated.ServingSize = *payload.ServingSize
+	}
+	if payload.ServingUnit != nil {
+		updated.ServingUnit = *payload.ServingUnit
+	}
+	if payload.Density != nil {
+		updated.Density = payload.Density
+		if *payload.Density == 0 {
+			updated.Density = nil
+		}
+	}
+
+	return &updated
+}
+
+// sameFood reports whether an edit leaves the food as it was, comparing what
+// the pointer fields point to rather than the pointers.
+func sameFood(a, b *store.Food) bool {
+	if !equalValues(a.Density, b.Density) || !equalValues(a.Barcode, b.Barcode) || !equalValues(a.UserID, b.UserID) {
+		return false
+	}
+
+	x, y := *a, *b
+	x.Density, y.Density = nil, nil
+	x.Barcode, y.Barcode = nil, nil
+	x.UserID, y.UserID = nil, nil
+
+	return x == y
+}
+
+func equalValues[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
 }
 
 // CreateFood godoc
 //
 //	@Summary		Creates a food
-//	@Description	Creates a food
+//	@Description	Adds a food to the catalog, private foods are only visible to the user who added them. Public foods are held for moderation unless added by an admin
 //	@Tags			foods
 //	@Accept			json
 //	@Produce		json
@@ -34,6 +139,7 @@ type CreateFoodPayload struct {
 //	@Success		201		{object}	store.Food
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/food [post]
@@ -47,20 +153,52 @@ func (app *Application) createFoodHandler(c *fiber.Ctx) error {
 		return app.badRequestResponse(c, err)
 	}
 
+	self := getSelfFromContext(c)
+
 	food := store.Food{
-		Name:        payload.Name,
-		Description: payload.Description,
-		Calories:    payload.Calories,
-		Protein:     payload.Protein,
-		Carbs:       payload.Carbs,
-		Fat:         payload.Fat,
-		Brand:       payload.Brand,
-		ServingSize: payload.ServingSize,
-		ServingUnit: payload.ServingUnit,
+		UserID:         &self.ID,
+		Visibility:     store.FoodPublic,
+		Name:           payload.Name,
+		Description:    payload.Description,
+		Calories:       payload.Calories,
+		Protein:        payload.Protein,
+		Carbs:          payload.Carbs,
+		Fat:            payload.Fat,
+		Fiber:          payload.Fiber,
+		Micronutrients: store.Micronutrients(payload.Micronutrients),
+		Brand:          payload.Brand,
+		ServingSize:    payload.ServingSize,
+		ServingUnit:    payload.ServingUnit,
+		Status:         store.FoodPending,
+	}
+	if payload.Visibility != "" {
+		food.Visibility = store.FoodVisibility(payload.Visibility)
+	}
+	if payload.Density > 0 {
+		food.Density = &payload.Density
+	}
+
+	if payload.Barcode != "" {
+		barcode, err := store.NormalizeBarcode(payload.Barcode)
+		if err != nil {
+			return app.badRequestResponse(c, err)
+		}
+		food.Barcode = &barcode
+	}
+
+	// foods added by admins skip the moderation queue
+	if self.Role.Level >= store.RoleLevelAdmin {
+		food.Status = store.FoodApproved
+		food.Verified = true
 	}
 
 	if err := app.store.Foods.Create(c.Context(), &food); err != nil {
-		return app.internalServerError(c, err)
+		switch {
+		case errors.Is(err, store.ErrDuplicateBarcode):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
 	}
 
 	if err := app.jsonResponse(c, http.StatusCreated, food); err != nil {
@@ -84,13 +222,38 @@ func (app *Application) createFoodHandler(c *fiber.Ctx) error {
 //	@Security		ApiKeyAuth
 //	@Router			/food/{id} [get]
 func (app *Application) getFoodHandler(c *fiber.Ctx) error {
-	id := c.Params("id")
-	uuid, err := uuid.Parse(id)
-	if err != nil {
+	food := getFoodFromContext(c)
+
+	if err := app.jsonResponse(c, http.StatusOK, food); err != nil {
 		return app.internalServerError(c, err)
 	}
 
-	food, err := app.store.Foods.GetByID(c.Context(), uuid)
+	return nil
+}
+
+// GetFoodByBarcode godoc
+//
+//	@Summary		Fetches a food by barcode
+//	@Description	Looks up a scanned EAN-13 or UPC-A barcode, the user's own food for it comes before the catalog's
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	path		string	true	"EAN-13 or UPC-A barcode"
+//	@Success		200		{object}	store.Food
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/barcode/{code} [get]
+func (app *Application) getFoodByBarcodeHandler(c *fiber.Ctx) error {
+	barcode, err := store.NormalizeBarcode(c.Params("code"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	food, err := app.store.Foods.GetByBarcode(c.Context(), barcode, self.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -106,3 +269,402 @@ func (app *Application) getFoodHandler(c *fiber.Ctx) error {
 
 	return nil
 }
+
+// UpdateFood godoc
+//
+//	@Summary		Updates a food
+//	@Description	Edits a food's details, allowed for the user who added it and for admins. The previous version is kept so entries logged before the edit keep their nutrition. Public foods edited by their creator go back to the moderation queue
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Food ID"
+//	@Param			payload	body		UpdateFoodPayload	true	"Food fields to change"
+//	@Success		200		{object}	store.Food
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id} [patch]
+func (app *Application) updateFoodHandler(c *fiber.Ctx) error {
+	var payload UpdateFoodPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	isOwner := food.UserID != nil && *food.UserID == self.ID
+	isAdmin := self.Role.Level >= store.RoleLevelAdmin
+	if !isOwner && !isAdmin {
+		return app.forbiddenResponse(c)
+	}
+
+	updated := payload.apply(food)
+	if sameFood(updated, food) {
+		if err := app.jsonResponse(c, http.StatusOK, food); err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		return nil
+	}
+
+	var err error
+	switch {
+	case !isOwner:
+		// an admin correcting someone else's food is a moderation action
+		err = app.store.Foods.ModeratorEdit(c.Context(), food, updated, self.ID)
+	default:
+		if !isAdmin && updated.Visibility == store.FoodPublic {
+			updated.Status = store.FoodPending
+			updated.Verified = false
+			updated.RejectionReason = ""
+		}
+
+		err = app.store.Foods.Update(c.Context(), updated, self.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("food was edited at the same time, try again"))
+		case errors.Is(err, store.ErrDuplicateBarcode):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, updated); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetFoodRevisions godoc
+//
+//	@Summary		Fetches a food's revision history
+//	@Description	Lists the previous versions of a food with when they were current and who replaced them, most recent first
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		200	{array}		store.FoodRevision
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/revisions [get]
+func (app *Application) getFoodRevisionsHandler(c *fiber.Ctx) error {
+	food := getFoodFromContext(c)
+
+	revisions, err := app.store.Foods.GetRevisions(c.Context(), food.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, revisions); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetAllFood godoc
+//
+//	@Summary		Fetches all food
+//	@Description	Searches the food catalog with filters and cursor pagination, verified foods rank first among similar matches
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			search			query		string	false	"Full-text search on the food name"
+//	@Param			brand			query		string	false	"Brand"
+//	@Param			verified		query		bool	false	"Only verified/unverified foods"
+//	@Param			min_calories	query		number	false	"Minimum calories"
+//	@Param			max_calories	query		number	false	"Maximum calories"
+//	@Param			min_protein		query		number	false	"Minimum protein"
+//	@Param			max_protein		query		number	false	"Maximum protein"
+//	@Param			min_carbs		query		number	false	"Minimum carbs"
+//	@Param			max_carbs		query		number	false	"Maximum carbs"
+//	@Param			min_fat			query		number	false	"Minimum fat"
+//	@Param			max_fat			query		number	false	"Maximum fat"
+//	@Param			limit			query		int		false	"Page size"
+//	@Param			cursor			query		string	false	"Cursor from the previous page"
+//	@Success		200				{object}	store.FoodPage
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food [get]
+func (app *Application) getAllFoodHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedFoodQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	fq.Viewer = getSelfFromContext(c).ID
+
+	page, err := app.store.Foods.Search(c.Context(), fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, page); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetFoodSuggestions godoc
+//
+//	@Summary		Fetches quick-add foods
+//	@Description	Fetches the foods the user logged most recently and the ones they log most often, in a meal and time of day when given, each with the serving they used last
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			meal_name	query		string	false	"Meal to rank frequent foods for"
+//	@Param			time_of_day	query		string	false	"morning, afternoon, evening or night"
+//	@Param			tz			query		string	false	"IANA time zone the time of day is in, defaults to UTC"
+//	@Success		200			{object}	store.FoodSuggestions
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/suggestions [get]
+func (app *Application) getFoodSuggestionsHandler(c *fiber.Ctx) error {
+	q, err := store.FoodSuggestionQuery{}.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	suggestions, err := app.getFoodSuggestions(c, self.ID, q)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, suggestions); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+func (app *Application) getFoodSuggestions(c *fiber.Ctx, userID uuid.UUID, q store.FoodSuggestionQuery) (*store.FoodSuggestions, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Meals.GetFoodSuggestions(c.Context(), userID, q)
+	}
+
+	suggestions, err := app.cacheStorage.Foods.GetSuggestions(c.Context(), userID, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if suggestions == nil {
+		suggestions, err = app.store.Meals.GetFoodSuggestions(c.Context(), userID, q)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := app.cacheStorage.Foods.SetSuggestions(c.Context(), userID, q, suggestions); err != nil {
+			return nil, err
+		}
+	}
+
+	return suggestions, nil
+}
+
+// invalidateFoodSuggestions drops the cached suggestions of a user once they
+// log something new. Failures are logged rather than failing the request since
+// the entries have been saved, the cache expires on its own.
+func (app *Application) invalidateFoodSuggestions(c *fiber.Ctx, userID uuid.UUID) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	if err := app.cacheStorage.Foods.DeleteSuggestions(c.Context(), userID); err != nil {
+		app.logger.Errorw("failed to invalidate food suggestions", "user", userID, "error", err)
+	}
+}
+
+// GetSelfFoods godoc
+//
+//	@Summary		Fetches the user's foods
+//	@Description	Lists the foods the logged in user added, private and public, newest first
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Success		200		{object}	store.FoodPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/foods [get]
+func (app *Application) getSelfFoodsHandler(c *fiber.Ctx) error {
+	return app.listSelfFoods(c, app.store.Foods.GetByUser)
+}
+
+// GetSelfFavouriteFoods godoc
+//
+//	@Summary		Fetches the user's favourite foods
+//	@Description	Lists the foods the logged in user favourited, most recently favourited first
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Success		200		{object}	store.FoodPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/favourites [get]
+func (app *Application) getSelfFavouriteFoodsHandler(c *fiber.Ctx) error {
+	return app.listSelfFoods(c, app.store.Foods.GetFavourites)
+}
+
+func (app *Application) listSelfFoods(c *fiber.Ctx, list func(context.Context, uuid.UUID, store.PaginatedQuery) (*store.FoodPage, error)) error {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	page, err := list(c.Context(), self.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, page); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// FavouriteFood godoc
+//
+//	@Summary		Favourites a food
+//	@Description	Adds a food to the logged in user's favourites
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		204	{string}	string	"Food favourited"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/favourite [put]
+func (app *Application) favouriteFoodHandler(c *fiber.Ctx) error {
+	food := getFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if err := app.store.Foods.Favourite(c.Context(), self.ID, food.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UnfavouriteFood godoc
+//
+//	@Summary		Unfavourites a food
+//	@Description	Removes a food from the logged in user's favourites
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		204	{string}	string	"Food unfavourited"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/unfavourite [put]
+func (app *Application) unfavouriteFoodHandler(c *fiber.Ctx) error {
+	food := getFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if err := app.store.Foods.Unfavourite(c.Context(), self.ID, food.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const foodCtxKey resourceKey = "food"
+
+// foodsContextMiddleware loads the food in the :id param. The catalog is
+// shared, but private and unapproved foods are reported missing to everyone but
+// their creator.
+func (app *Application) foodsContextMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		self := getSelfFromContext(c)
+
+		getVisible := func(ctx context.Context, id uuid.UUID) (*store.Food, error) {
+			food, err := app.store.Foods.GetByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+
+			if !food.VisibleTo(self.ID) {
+				return nil, store.ErrNotFound
+			}
+
+			return food, nil
+		}
+
+		return loadResource(app, foodCtxKey, getVisible, nil)(c)
+	}
+}
+
+func getFoodFromContext(c *fiber.Ctx) *store.Food {
+	return getResourceFromContext[store.Food](c, foodCtxKey)
+}
@@ -54,6 +54,7 @@ func main() {
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
 			exp:       time.Hour * 24 * 3,
+			resetExp:  time.Hour,
 			fromEmail: env.GetString("FROM_EMAIL", ""),
 			sendGrid: sendGridConfig{
 				apiKey: env.GetString("SENDGRID_API_KEY", ""),
@@ -65,15 +66,27 @@ func main() {
 				password: env.GetString("AUTH_BASIC_PASSWORD", "password"),
 			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "basic_secret123"),
-				exp:    time.Hour * 24 * 3, // 3 days
-				iss:    env.GetString("AUTH_TOKEN_ISSUER", "workoutapp"),
+				secret:     env.GetString("AUTH_TOKEN_SECRET", "basic_secret123"),
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour * 24 * 30, // 30 days
+				iss:        env.GetString("AUTH_TOKEN_ISSUER", "workoutapp"),
 			},
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestsPerTimeFrame: env.GetInt("RATE_LIMIT_REQUESTS", 20),
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
+			Algorithm:            ratelimiter.Algorithm(env.GetString("RATE_LIMIT_ALGORITHM", string(ratelimiter.TokenBucket))),
+		},
+		authRateLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: env.GetInt("RATE_LIMIT_AUTH_REQUESTS", 5),
+			TimeFrame:            time.Minute,
+			Enabled:              env.GetBool("RATE_LIMIT_ENABLED", true),
+			Algorithm:            ratelimiter.SlidingLog,
+		},
+		duplicates: duplicatesConfig{
+			enabled:  env.GetBool("DUPLICATE_DETECTION_ENABLED", true),
+			interval: time.Hour * 6,
 		},
 	}
 
@@ -112,23 +125,29 @@ func main() {
 		cfg.auth.token.iss,
 	)
 
-	// Rate limiter
-	rateLimiter := ratelimiter.NewFixedWindowLimiter(
-		cfg.rateLimiter.RequestsPerTimeFrame,
-		cfg.rateLimiter.TimeFrame,
-	)
+	// Rate limiter, backed by redis when enabled so that limits hold across replicas
+	rateLimiter, err := ratelimiter.New(rdb, "default", cfg.rateLimiter)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	authRateLimiter, err := ratelimiter.New(rdb, "auth", cfg.authRateLimiter)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	store := store.NewStorage(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
 
 	app := &Application{
-		config:        cfg,
-		store:         store,
-		cacheStorage:  cacheStorage,
-		logger:        logger,
-		mailer:        mailer,
-		authenticator: jwtAuthenticator,
-		rateLimiter:   rateLimiter,
+		config:          cfg,
+		store:           store,
+		cacheStorage:    cacheStorage,
+		logger:          logger,
+		mailer:          mailer,
+		authenticator:   jwtAuthenticator,
+		rateLimiter:     rateLimiter,
+		authRateLimiter: authRateLimiter,
 	}
 
 	router := app.mount()
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+type MealTemplateEntryPayload struct {
+	FoodID      string  `json:"food_id" validate:"required_without=RecipeID,excluded_with=RecipeID,omitempty,uuid"`
+	RecipeID    string  `json:"recipe_id" validate:"required_without=FoodID,omitempty,uuid"`
+	ServingUnit string  `json:"serving_unit" validate:"required_with=FoodID"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// CreateMealTemplatePayload saves either the entries of one of the user's
+// meals, given by MealID, or the listed entries.
+type CreateMealTemplatePayload struct {
+	Name     string                     `json:"name" validate:"required,max=255"`
+	MealName string                     `json:"meal_name" validate:"required,oneof=breakfast lunch dinner snacks"`
+	MealID   string                     `json:"meal_id" validate:"required_without=Entries,excluded_with=Entries,omitempty,uuid"`
+	Entries  []MealTemplateEntryPayload `json:"entries" validate:"required_without=MealID,max=50,dive"`
+}
+
+type ApplyMealTemplatePayload struct {
+	ConsumedAt string `json:"consumed_at" validate:"required"`
+	MealName   string `json:"meal_name" validate:"omitempty,oneof=breakfast lunch dinner snacks"`
+}
+
+// CreateMealTemplate godoc
+//
+//	@Summary		Saves a meal template
+//	@Description	Saves a named set of foods and recipes, either listed or taken from one of the user's meals, to log again in one call
+//	@Tags			meal templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateMealTemplatePayload	true	"Meal template payload"
+//	@Success		201		{object}	store.MealTemplate
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/templates [post]
+func (app *Application) createMealTemplateHandler(c *fiber.Ctx) error {
+	var payload CreateMealTemplatePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	template := store.MealTemplate{
+		UserID:   self.ID,
+		Name:     payload.Name,
+		MealName: payload.MealName,
+	}
+
+	if payload.MealID != "" {
+		entries, err := app.getOwnMealEntries(c, uuid.MustParse(payload.MealID))
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.notFoundResponse(c, err)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+
+		for i, entry := range entries {
+			template.Entries = append(template.Entries, store.MealTemplateEntry{
+				FoodID:      entry.FoodID,
+				RecipeID:    entry.RecipeID,
+				Position:    i,
+				ServingUnit: entry.ServingUnit,
+				Amount:      entry.Amount,
+			})
+		}
+	}
+
+	for _, entry := range payload.Entries {
+		templateEntry := store.MealTemplateEntry{
+			Position:    len(template.Entries),
+			ServingUnit: entry.ServingUnit,
+			Amount:      entry.Amount,
+		}
+
+		if entry.RecipeID != "" {
+			recipe, err := getOwnedResource(c, uuid.MustParse(entry.RecipeID), app.store.Recipes.GetByID, recipeOwner)
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrNotFound):
+					return app.badRequestResponse(c, store.ErrUnknownRecipe)
+				default:
+					return app.internalServerError(c, err)
+				}
+			}
+
+			templateEntry.RecipeID = &recipe.ID
+			templateEntry.ServingUnit = store.RecipeServingUnit
+		} else {
+			food, err := app.checkServingUnit(c, uuid.MustParse(entry.FoodID), entry.ServingUnit)
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrUnknownFood), errors.Is(err, units.ErrUnsupportedUnit):
+					return app.badRequestResponse(c, err)
+				default:
+					return app.internalServerError(c, err)
+				}
+			}
+
+			templateEntry.FoodID = &food.ID
+			templateEntry.ServingUnit = units.Normalize(entry.ServingUnit)
+		}
+
+		template.Entries = append(template.Entries, templateEntry)
+	}
+
+	if len(template.Entries) == 0 {
+		return app.badRequestResponse(c, errors.New("a template needs at least one entry"))
+	}
+
+	if err := app.store.MealTemplates.Create(c.Context(), &template); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("a template with that name already exists"))
+		case errors.Is(err, store.ErrUnknownFood), errors.Is(err, store.ErrUnknownRecipe):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, template); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetMealTemplates godoc
+//
+//	@Summary		Fetches the user's meal templates
+//	@Description	Lists the logged in user's saved meal templates
+//	@Tags			meal templates
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		store.MealTemplate
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/templates [get]
+func (app *Application) getMealTemplatesHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	templates, err := app.store.MealTemplates.GetByUser(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, templates); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DeleteMealTemplate godoc
+//
+//	@Summary		Deletes a meal template
+//	@Description	Deletes a meal template by ID, meals logged from it are kept
+//	@Tags			meal templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Meal template ID"
+//	@Success		204	{object}	string
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/templates/{id} [delete]
+func (app *Application) deleteMealTemplateHandler(c *fiber.Ctx) error {
+	template := getMealTemplateFromContext(c)
+
+	if err := app.store.MealTemplates.Delete(c.Context(), template.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// ApplyMealTemplate godoc
+//
+//	@Summary		Logs a meal template
+//	@Description	Logs every entry of a template to the meal of the given time, the template's own meal unless meal_name is given
+//	@Tags			meal templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"Meal template ID"
+//	@Param			payload	body		ApplyMealTemplatePayload	true	"Apply payload"
+//	@Success		201		{array}		store.MealEntry
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/templates/{id}/apply [post]
+func (app *Application) applyMealTemplateHandler(c *fiber.Ctx) error {
+	var payload ApplyMealTemplatePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	template := getMealTemplateFromContext(c)
+	if len(template.Entries) == 0 {
+		return app.badRequestResponse(c, errors.New("template has no entries left"))
+	}
+
+	meal := store.Meal{
+		UserID: template.UserID,
+		Name:   template.MealName,
+		Date:   payload.ConsumedAt,
+	}
+	if payload.MealName != "" {
+		meal.Name = payload.MealName
+	}
+
+	entries, err := app.store.MealTemplates.Apply(c.Context(), template, &meal, payload.ConsumedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownFood), errors.Is(err, store.ErrUnknownRecipe):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	app.invalidateFoodSuggestions(c, template.UserID)
+
+	if err := app.jsonResponse(c, http.StatusCreated, entries); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// getOwnMealEntries fetches the entries of one of the user's meals, treating
+// meals of other users as missing.
+func (app *Application) getOwnMealEntries(c *fiber.Ctx, mealID uuid.UUID) ([]store.MealEntry, error) {
+	meal, err := app.store.Meals.GetMealByID(c.Context(), mealID)
+	if err != nil {
+		return nil, err
+	}
+
+	if meal.UserID != getSelfFromContext(c).ID {
+		return nil, store.ErrNotFound
+	}
+
+	return app.store.Meals.GetMealEntries(c.Context(), meal.ID)
+}
+
+const mealTemplateCtxKey resourceKey = "mealTemplate"
+
+func (app *Application) mealTemplatesContextMiddleware() fiber.Handler {
+	return loadResource(app, mealTemplateCtxKey, app.store.MealTemplates.GetByID, func(template *store.MealTemplate) uuid.UUID {
+		return template.UserID
+	})
+}
+
+func getMealTemplateFromContext(c *fiber.Ctx) *store.MealTemplate {
+	return getResourceFromContext[store.MealTemplate](c, mealTemplateCtxKey)
+}
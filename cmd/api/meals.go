@@ -2,16 +2,22 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/zondaf12/workout-app-backend/internal/store"
+	"github.com/zondaf12/workout-app-backend/internal/units"
 )
 
+// CreateMealEntryPayload logs either a food, in any unit that converts to its
+// serving unit, or a number of servings of one of the user's recipes.
 type CreateMealEntryPayload struct {
-	FoodID      string  `json:"food_id" validate:"required"`
-	ServingUnit string  `json:"serving_unit" validate:"required"`
+	FoodID      string  `json:"food_id" validate:"required_without=RecipeID,excluded_with=RecipeID"`
+	RecipeID    string  `json:"recipe_id" validate:"required_without=FoodID"`
+	ServingUnit string  `json:"serving_unit" validate:"required_with=FoodID"`
 	Amount      float64 `json:"amount" validate:"required"`
 	ConsumedAt  string  `json:"consumed_at" validate:"required"`
 	MealName    string  `json:"meal_name" validate:"required"`
@@ -27,7 +33,7 @@ type UpdateMealEntryPayload struct {
 // CreateMealEntry godoc
 //
 //	@Summary		Creates a meal entry
-//	@Description	Creates a meal entry
+//	@Description	Logs a food in a unit of mass or volume, one of its portions or "serving", or a number of servings of one of the user's recipes, to a meal
 //	@Tags			meal entrys
 //	@Accept			json
 //	@Produce		json
@@ -50,6 +56,49 @@ func (app *Application) createMealEntryHandler(c *fiber.Ctx) error {
 
 	self := getSelfFromContext(c)
 
+	newEntry := store.MealEntry{
+		ServingUnit: payload.ServingUnit,
+		Amount:      payload.Amount,
+		ConsumedAt:  payload.ConsumedAt,
+	}
+	if payload.RecipeID != "" {
+		recipeID, err := uuid.Parse(payload.RecipeID)
+		if err != nil {
+			return app.badRequestResponse(c, err)
+		}
+
+		recipe, err := getOwnedResource(c, recipeID, app.store.Recipes.GetByID, recipeOwner)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.badRequestResponse(c, store.ErrUnknownRecipe)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+
+		newEntry.RecipeID = &recipe.ID
+		newEntry.ServingUnit = store.RecipeServingUnit
+	} else {
+		foodID, err := uuid.Parse(payload.FoodID)
+		if err != nil {
+			return app.badRequestResponse(c, err)
+		}
+
+		food, err := app.checkServingUnit(c, foodID, payload.ServingUnit)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrUnknownFood), errors.Is(err, units.ErrUnsupportedUnit):
+				return app.badRequestResponse(c, err)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+
+		newEntry.FoodID = &food.ID
+		newEntry.ServingUnit = units.Normalize(payload.ServingUnit)
+	}
+
 	checkMeal := store.Meal{
 		UserID: self.ID,
 		Name:   payload.MealName,
@@ -75,17 +124,18 @@ func (app *Application) createMealEntryHandler(c *fiber.Ctx) error {
 		mealID = checkMeal.ID
 	}
 
-	newEntry := store.MealEntry{
-		MealID:      mealID,
-		FoodID:      uuid.MustParse(payload.FoodID),
-		ServingUnit: payload.ServingUnit,
-		Amount:      payload.Amount,
-		ConsumedAt:  payload.ConsumedAt,
-	}
+	newEntry.MealID = mealID
 	if err := app.store.Meals.CreateMealEntry(c.Context(), &newEntry); err != nil {
-		return app.internalServerError(c, err)
+		switch {
+		case errors.Is(err, store.ErrUnknownFood), errors.Is(err, store.ErrUnknownRecipe):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
 	}
 
+	app.invalidateFoodSuggestions(c, self.ID)
+
 	if err := app.jsonResponse(c, fiber.StatusCreated, newEntry); err != nil {
 		return app.internalServerError(c, err)
 	}
@@ -110,11 +160,6 @@ func (app *Application) createMealEntryHandler(c *fiber.Ctx) error {
 //	@Security		ApiKeyAuth
 //	@Router			/meal/{id} [patch]
 func (app *Application) updateMealEntryHandler(c *fiber.Ctx) error {
-	mealID, err := uuid.Parse(c.Params("id"))
-	if err != nil {
-		return app.badRequestResponse(c, err)
-	}
-
 	var payload UpdateMealEntryPayload
 	if err := readJSON(c, &payload); err != nil {
 		return app.badRequestResponse(c, err)
@@ -124,40 +169,40 @@ func (app *Application) updateMealEntryHandler(c *fiber.Ctx) error {
 		return app.badRequestResponse(c, err)
 	}
 
-	/* Get the meal entry */
-	entry, err := app.store.Meals.GetMealEntryByID(c.Context(), mealID)
-	if err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			return app.notFoundResponse(c, err)
-		default:
-			return app.internalServerError(c, err)
-		}
-	}
-
-	/* Get the current meal from that entry */
-	currentMeal, err := app.store.Meals.GetMealByID(c.Context(), entry.MealID)
-	if err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			return app.notFoundResponse(c, err)
-		default:
-			return app.internalServerError(c, err)
-		}
-	}
+	/* Get the meal entry and the meal it is currently on */
+	entry := getMealEntryFromContext(c)
+	currentMeal := entry.Meal
 
 	/* Compare to see if the payload changes the meal that the entry is on */
 	updatedEntry := store.MealEntry{
-		ID:          mealID,
+		ID:          entry.ID,
 		MealID:      entry.MealID,
 		FoodID:      entry.FoodID,
+		RecipeID:    entry.RecipeID,
 		ServingUnit: payload.ServingUnit,
 		Amount:      payload.Amount,
 		ConsumedAt:  payload.ConsumedAt,
 	}
-	if currentMeal.Name != payload.MealName {
-		self := getSelfFromContext(c)
+	if entry.RecipeID != nil {
+		updatedEntry.ServingUnit = store.RecipeServingUnit
+	} else {
+		food, err := app.getReferencedFood(c, *entry.FoodID)
+		if err == nil {
+			err = app.checkFoodUnit(c, food, payload.ServingUnit)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrUnknownFood), errors.Is(err, units.ErrUnsupportedUnit):
+				return app.badRequestResponse(c, err)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
 
+		updatedEntry.ServingUnit = units.Normalize(payload.ServingUnit)
+	}
+	self := getSelfFromContext(c)
+	if currentMeal.Name != payload.MealName {
 		checkMeal := store.Meal{
 			UserID: self.ID,
 			Name:   payload.MealName,
@@ -190,6 +235,8 @@ func (app *Application) updateMealEntryHandler(c *fiber.Ctx) error {
 		return app.internalServerError(c, err)
 	}
 
+	app.invalidateFoodSuggestions(c, self.ID)
+
 	if err := app.jsonResponse(c, fiber.StatusOK, updatedEntry); err != nil {
 		return app.internalServerError(c, err)
 	}
@@ -211,12 +258,239 @@ func (app *Application) updateMealEntryHandler(c *fiber.Ctx) error {
 //	@Security		ApiKeyAuth
 //	@Router			/meal/{id} [delete]
 func (app *Application) deleteMealEntryHandler(c *fiber.Ctx) error {
-	mealID, err := uuid.Parse(c.Params("id"))
+	entry := getMealEntryFromContext(c)
+
+	if err := app.store.Meals.DeleteMealEntry(c.Context(), entry.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	app.invalidateFoodSuggestions(c, getSelfFromContext(c).ID)
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+type CopyMealsPayload struct {
+	FromDate   string `json:"from_date" validate:"required,datetime=2006-01-02"`
+	ToDate     string `json:"to_date" validate:"required,datetime=2006-01-02"`
+	MealName   string `json:"meal_name" validate:"omitempty,oneof=breakfast lunch dinner snacks"`
+	ToMealName string `json:"to_meal_name" validate:"excluded_without=MealName,omitempty,oneof=breakfast lunch dinner snacks"`
+}
+
+// CopyMeals godoc
+//
+//	@Summary		Copies meals to another date
+//	@Description	Logs everything eaten on one date again on another, or only one of its meals when meal_name is given, optionally into a different meal
+//	@Tags			meal entrys
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CopyMealsPayload	true	"Copy payload"
+//	@Success		201		{array}		store.MealEntry
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/copy [post]
+func (app *Application) copyMealsHandler(c *fiber.Ctx) error {
+	var payload CopyMealsPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if payload.FromDate == payload.ToDate && (payload.ToMealName == "" || payload.ToMealName == payload.MealName) {
+		return app.badRequestResponse(c, errors.New("cannot copy a meal onto itself"))
+	}
+
+	from, _ := time.Parse(time.DateOnly, payload.FromDate)
+	to, _ := time.Parse(time.DateOnly, payload.ToDate)
+
+	self := getSelfFromContext(c)
+
+	entries, err := app.store.Meals.CopyMeals(c.Context(), self.ID, from, to, payload.MealName, payload.ToMealName)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	app.invalidateFoodSuggestions(c, self.ID)
+
+	if err := app.jsonResponse(c, http.StatusCreated, entries); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const maxDiaryRangeDays = 31
+
+// GetMealDiary godoc
+//
+//	@Summary		Fetches the nutrition diary
+//	@Description	Fetches the logged meals for a date, or a date range, with per-meal and per-day totals and what is left of the day's targets
+//	@Tags			meal entrys
+//	@Accept			json
+//	@Produce		json
+//	@Param			date	query		string	false	"Day to fetch (YYYY-MM-DD), defaults to today"
+//	@Param			from	query		string	false	"Start of the range (YYYY-MM-DD)"
+//	@Param			to		query		string	false	"End of the range (YYYY-MM-DD)"
+//	@Success		200		{array}		store.DiaryDay
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals [get]
+func (app *Application) getMealDiaryHandler(c *fiber.Ctx) error {
+	from, to, err := parseDiaryRange(c)
 	if err != nil {
 		return app.badRequestResponse(c, err)
 	}
 
-	if err := app.store.Meals.DeleteMealEntry(c.Context(), mealID); err != nil {
+	self := getSelfFromContext(c)
+
+	days, err := app.store.Meals.GetDiary(c.Context(), self.ID, from, to)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	targets, err := app.store.Nutrition.GetTargetsHistory(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	for i := range days {
+		if dayTargets := store.TargetsOn(targets, days[i].Date); dayTargets != nil {
+			days[i].SetTargets(dayTargets.NutritionTotals)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, days); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+func parseDiaryRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	if c.Query("from") == "" && c.Query("to") == "" {
+		date := c.Query("date", time.Now().UTC().Format(time.DateOnly))
+
+		day, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+
+		return day, day, nil
+	}
+
+	from, err := time.Parse(time.DateOnly, c.Query("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", c.Query("from"))
+	}
+
+	to, err := time.Parse(time.DateOnly, c.Query("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", c.Query("to"))
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to date must not be before from date")
+	}
+
+	if to.Sub(from) >= maxDiaryRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range must not exceed %d days", maxDiaryRangeDays)
+	}
+
+	return from, to, nil
+}
+
+// ShareMeal godoc
+//
+//	@Summary		Shares a meal
+//	@Description	Shares a logged meal with the user's followers
+//	@Tags			meal entrys
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Meal ID"
+//	@Success		204	{string}	string	"Meal shared"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/{id}/share [put]
+func (app *Application) shareMealHandler(c *fiber.Ctx) error {
+	meal := getMealFromContext(c)
+
+	if !meal.Shared {
+		date, err := time.Parse(time.RFC3339, meal.Date)
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		days, err := app.store.Meals.GetDiary(c.Context(), meal.UserID, date, date)
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		data := mealActivityData{Name: meal.Name, Date: date.Format(time.DateOnly), Foods: []string{}}
+		for _, diaryMeal := range days[0].Meals {
+			if diaryMeal.Name != meal.Name {
+				continue
+			}
+
+			for _, entry := range diaryMeal.Entries {
+				data.Foods = append(data.Foods, entry.Food.Name)
+			}
+			data.Totals = diaryMeal.Totals
+		}
+
+		if err := app.store.Meals.SetMealShared(c.Context(), meal.ID, true); err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		if err := app.publishActivity(c, meal.UserID, meal.ID, store.ActivityMeal, data); err != nil {
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UnshareMeal godoc
+//
+//	@Summary		Unshares a meal
+//	@Description	Stops sharing a meal and removes it from followers' feeds
+//	@Tags			meal entrys
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Meal ID"
+//	@Success		204	{string}	string	"Meal unshared"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meals/{id}/unshare [put]
+func (app *Application) unshareMealHandler(c *fiber.Ctx) error {
+	meal := getMealFromContext(c)
+
+	if err := app.store.Meals.SetMealShared(c.Context(), meal.ID, false); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.store.Activities.DeleteByReference(c.Context(), meal.ID); err != nil {
 		return app.internalServerError(c, err)
 	}
 
@@ -226,3 +500,94 @@ func (app *Application) deleteMealEntryHandler(c *fiber.Ctx) error {
 
 	return nil
 }
+
+const (
+	mealCtxKey      resourceKey = "meal"
+	mealEntryCtxKey resourceKey = "mealEntry"
+)
+
+// getVisibleFood fetches a food given in a payload, a merged food's id resolves
+// to the food it was merged into. Foods the user can't see are as unknown as
+// missing ones.
+func (app *Application) getVisibleFood(c *fiber.Ctx, foodID uuid.UUID) (*store.Food, error) {
+	food, err := app.getReferencedFood(c, foodID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !food.VisibleTo(getSelfFromContext(c).ID) {
+		return nil, store.ErrUnknownFood
+	}
+
+	return food, nil
+}
+
+// getReferencedFood fetches a food the user already logged or cooked with.
+// Those keep resolving after the food stops being visible to them, say while
+// its creator's edit waits for moderation, so existing entries and recipes can
+// still be edited.
+func (app *Application) getReferencedFood(c *fiber.Ctx, foodID uuid.UUID) (*store.Food, error) {
+	food, err := app.store.Foods.GetByID(c.Context(), foodID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return nil, store.ErrUnknownFood
+		default:
+			return nil, err
+		}
+	}
+
+	return food, nil
+}
+
+// checkServingUnit makes sure the user can see the food and that amounts of it
+// in the unit can be converted to its serving unit, and returns the food.
+func (app *Application) checkServingUnit(c *fiber.Ctx, foodID uuid.UUID, unit string) (*store.Food, error) {
+	food, err := app.getVisibleFood(c, foodID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.checkFoodUnit(c, food, unit); err != nil {
+		return nil, err
+	}
+
+	return food, nil
+}
+
+// checkFoodUnit makes sure amounts of the food in the unit can be converted to
+// its serving unit.
+func (app *Application) checkFoodUnit(c *fiber.Ctx, food *store.Food, unit string) error {
+	portions, err := app.store.Foods.GetPortions(c.Context(), food.ID)
+	if err != nil {
+		return err
+	}
+
+	if !food.Measure(portions).Supports(unit) {
+		return fmt.Errorf("%w, it is measured in %s", units.ErrUnsupportedUnit, food.ServingUnit)
+	}
+
+	return nil
+}
+
+func (app *Application) mealsContextMiddleware() fiber.Handler {
+	return loadResource(app, mealCtxKey, app.store.Meals.GetMealByID, func(meal *store.Meal) uuid.UUID {
+		return meal.UserID
+	})
+}
+
+// mealEntriesContextMiddleware loads the entry along with its meal, since the
+// meal is what records who the entry belongs to.
+func (app *Application) mealEntriesContextMiddleware() fiber.Handler {
+	return loadResource(app, mealEntryCtxKey, app.store.Meals.GetMealEntryWithMeal, func(entry *store.MealEntryWithMeal) uuid.UUID {
+		return entry.Meal.UserID
+	})
+}
+
+func getMealFromContext(c *fiber.Ctx) *store.Meal {
+	return getResourceFromContext[store.Meal](c, mealCtxKey)
+}
+
+func getMealEntryFromContext(c *fiber.Ctx) *store.MealEntryWithMeal {
+	return getResourceFromContext[store.MealEntryWithMeal](c, mealEntryCtxKey)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type CreateMeasurementPayload struct {
+	Type       string  `json:"type" validate:"required,max=50"`
+	Value      float64 `json:"value" validate:"required,gt=0"`
+	Unit       string  `json:"unit" validate:"required,oneof=kg lb st cm mm in %"`
+	MeasuredAt string  `json:"measured_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Notes      string  `json:"notes" validate:"max=500"`
+}
+
+const (
+	defaultTrendDays = 90
+	maxTrendDays     = 730
+)
+
+// GetSelfMeasurements godoc
+//
+//	@Summary		Fetches the user's measurements
+//	@Description	Fetches the logged body measurements of the user, newest first, optionally of a single type
+//	@Tags			measurements
+//	@Accept			json
+//	@Produce		json
+//	@Param			type	query		string	false	"Measurement type, e.g. weight"
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	store.MeasurementPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/measurements [get]
+func (app *Application) getSelfMeasurementsHandler(c *fiber.Ctx) error {
+	mq := store.PaginatedMeasurementQuery{
+		PaginatedQuery: store.PaginatedQuery{
+			Limit: 20,
+		},
+	}
+
+	mq, err := mq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(mq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	page, err := app.store.Measurements.GetByUser(c.Context(), self.ID, mq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, page); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// CreateSelfMeasurement godoc
+//
+//	@Summary		Logs a measurement
+//	@Description	Logs a body measurement such as weight, body fat or a circumference. Values are stored in kg, cm or %, whichever unit they are logged in. Weight and body fat also update the nutrition profile
+//	@Tags			measurements
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateMeasurementPayload	true	"Measurement payload"
+//	@Success		201		{object}	store.Measurement
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/measurements [post]
+func (app *Application) createSelfMeasurementHandler(c *fiber.Ctx) error {
+	var payload CreateMeasurementPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	value, unit, err := store.NormalizeMeasurement(payload.Type, payload.Value, payload.Unit)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	measurement := store.Measurement{
+		UserID:       self.ID,
+		Type:         payload.Type,
+		Value:        value,
+		Unit:         unit,
+		EnteredValue: payload.Value,
+		EnteredUnit:  payload.Unit,
+		Notes:        payload.Notes,
+		MeasuredAt:   payload.MeasuredAt,
+	}
+	if measurement.MeasuredAt == "" {
+		measurement.MeasuredAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	if err := app.store.Measurements.Create(c.Context(), &measurement); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	app.syncNutritionProfile(c, self.ID, measurement.Type)
+
+	if err := app.jsonResponse(c, http.StatusCreated, measurement); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DeleteSelfMeasurement godoc
+//
+//	@Summary		Deletes a measurement
+//	@Description	Deletes a logged measurement by ID
+//	@Tags			measurements
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Measurement ID"
+//	@Success		204	{object}	string
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/measurements/{id} [delete]
+func (app *Application) deleteSelfMeasurementHandler(c *fiber.Ctx) error {
+	measurement := getMeasurementFromContext(c)
+
+	if err := app.store.Measurements.Delete(c.Context(), measurement.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	app.syncNutritionProfile(c, measurement.UserID, measurement.Type)
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetSelfMeasurementTrend godoc
+//
+//	@Summary		Fetches a measurement trend
+//	@Description	Fetches the daily values of a measurement type with an exponentially smoothed trend, the current trend value and its weekly rate of change
+//	@Tags			measurements
+//	@Accept			json
+//	@Produce		json
+//	@Param			type	query		string	false	"Measurement type, defaults to weight"
+//	@Param			days	query		int		false	"Number of days to return, defaults to 90"
+//	@Success		200		{object}	store.MeasurementTrend
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/measurements/trend [get]
+func (app *Application) getSelfMeasurementTrendHandler(c *fiber.Ctx) error {
+	measurementType := c.Query("type", store.MeasurementWeight)
+
+	days := defaultTrendDays
+	if d := c.Query("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 1 || parsed > maxTrendDays {
+			return app.badRequestResponse(c, errors.New("days must be between 1 and 730"))
+		}
+
+		days = parsed
+	}
+
+	self := getSelfFromContext(c)
+	from := time.Now().UTC().AddDate(0, 0, -(days - 1))
+
+	trend, err := app.store.Measurements.GetTrend(c.Context(), self.ID, measurementType, from)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, trend); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// syncNutritionProfile moves the profile's weight or body fat to the latest
+// trend value so calorie estimates follow the user's progress. Failures are
+// logged rather than failing the request since the measurement itself changed.
+func (app *Application) syncNutritionProfile(c *fiber.Ctx, userID uuid.UUID, measurementType string) {
+	if measurementType != store.MeasurementWeight && measurementType != store.MeasurementBodyFat {
+		return
+	}
+
+	trend, err := app.store.Measurements.GetTrend(c.Context(), userID, measurementType, time.Now().UTC())
+	if err != nil {
+		app.logger.Errorw("failed to get measurement trend", "user", userID, "type", measurementType, "error", err)
+		return
+	}
+
+	if trend.Current == nil {
+		return
+	}
+
+	var weightKg, bodyFatPercent *float64
+	if measurementType == store.MeasurementWeight {
+		weightKg = trend.Current
+	} else {
+		bodyFatPercent = trend.Current
+	}
+
+	if err := app.store.Nutrition.UpdateBodyComposition(c.Context(), userID, weightKg, bodyFatPercent); err != nil {
+		app.logger.Errorw("failed to update nutrition profile", "user", userID, "type", measurementType, "error", err)
+	}
+}
+
+const measurementCtxKey resourceKey = "measurement"
+
+func (app *Application) measurementsContextMiddleware() fiber.Handler {
+	return loadResource(app, measurementCtxKey, app.store.Measurements.GetByID, func(measurement *store.Measurement) uuid.UUID {
+		return measurement.UserID
+	})
+}
+
+func getMeasurementFromContext(c *fiber.Ctx) *store.Measurement {
+	return getResourceFromContext[store.Measurement](c, measurementCtxKey)
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/ratelimiter"
 	"github.com/zondaf12/workout-app-backend/internal/store"
+	"github.com/zondaf12/workout-app-backend/internal/store/cache"
 )
 
 func (app *Application) AuthTokenMiddleware() fiber.Handler {
@@ -40,19 +47,95 @@ func (app *Application) AuthTokenMiddleware() fiber.Handler {
 			return app.unauthorizedErrorResponse(c, err)
 		}
 
+		// reject tokens that were revoked on logout
+		jti, _ := claims["jti"].(string)
+		tokenID, err := uuid.Parse(jti)
+		if err != nil {
+			return app.unauthorizedErrorResponse(c, fmt.Errorf("invalid token id"))
+		}
+
+		revoked, err := app.isTokenRevoked(c, tokenID)
+		if err != nil {
+			return app.internalServerError(c, err)
+		}
+
+		if revoked {
+			return app.unauthorizedErrorResponse(c, fmt.Errorf("token has been revoked"))
+		}
+
 		// get the user from the store
 		user, err := app.getUser(c, userID)
 		if err != nil {
 			return app.unauthorizedErrorResponse(c, err)
 		}
 
+		// sessions end when the password is reset
+		if user.PasswordChangedAt != nil {
+			issuedAt, err := claims.GetIssuedAt()
+			if err != nil || issuedAt == nil || issuedAt.Before(*user.PasswordChangedAt) {
+				return app.unauthorizedErrorResponse(c, fmt.Errorf("token was issued before the password changed"))
+			}
+		}
+
 		// set the user in the context
 		c.Locals(selfCtxKey, user)
+		c.Locals(claimsCtxKey, claims)
 
 		return c.Next()
 	}
 }
 
+func (app *Application) isTokenRevoked(c *fiber.Ctx, jti uuid.UUID) (bool, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.Tokens.IsAccessTokenRevoked(c.Context(), jti)
+	}
+
+	revoked, found, err := app.cacheStorage.Tokens.IsRevoked(c.Context(), jti)
+	if err != nil {
+		return false, err
+	}
+
+	if found {
+		return revoked, nil
+	}
+
+	revoked, err = app.store.Tokens.IsAccessTokenRevoked(c.Context(), jti)
+	if err != nil {
+		return false, err
+	}
+
+	if err := app.cacheStorage.Tokens.SetRevoked(c.Context(), jti, revoked, cache.TokenCheckExpTime); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (app *Application) revokeAccessToken(c *fiber.Ctx, claims jwt.MapClaims) error {
+	jti, err := uuid.Parse(claims["jti"].(string))
+	if err != nil {
+		return err
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return err
+	}
+
+	if err := app.store.Tokens.RevokeAccessToken(c.Context(), jti, exp.Time); err != nil {
+		return err
+	}
+
+	if app.config.redisCfg.enabled {
+		// overwrite any cached "not revoked" answer until the token expires anyway
+		if ttl := time.Until(exp.Time); ttl > 0 {
+			return app.cacheStorage.Tokens.SetRevoked(c.Context(), jti, true, ttl)
+		}
+	}
+
+	return nil
+}
+
 func (app *Application) getUser(c *fiber.Ctx, userID uuid.UUID) (*store.User, error) {
 	if !app.config.redisCfg.enabled {
 		return app.store.Users.GetByID(c.Context(), userID)
@@ -77,6 +160,17 @@ func (app *Application) getUser(c *fiber.Ctx, userID uuid.UUID) (*store.User, er
 	return user, nil
 }
 
+// invalidateUser drops the cached copy of a user whose role, password or
+// existence changed, so the next request loads them from the database instead
+// of trusting a stale role until the cache expires.
+func (app *Application) invalidateUser(c *fiber.Ctx, userID uuid.UUID) {
+	if !app.config.redisCfg.enabled {
+		return
+	}
+
+	app.cacheStorage.Users.Delete(c.Context(), userID)
+}
+
 func (app *Application) BasicAuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// read the username and password from the request
@@ -121,26 +215,117 @@ func (app *Application) BasicAuthMiddleware() fiber.Handler {
 	}
 }
 
-func (app *Application) checkMealOwnership() fiber.Handler {
+// RequireRole only lets through users whose role is at least the given level,
+// it must run after AuthTokenMiddleware.
+func (app *Application) RequireRole(level int) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := getSelfFromContext(c)
-		meal := getMealEntryFromContext(c)
+		if user == nil {
+			return app.unauthorizedErrorResponse(c, fmt.Errorf("no authenticated user"))
+		}
 
-		if meal.Meal.UserID == user.ID {
-			return c.Next()
+		if user.Role.Level < level {
+			return app.forbiddenResponse(c)
 		}
 
-		return app.forbiddenResponse(c)
+		return c.Next()
 	}
 }
 
-func (app *Application) RateLimiterMiddleware() fiber.Handler {
+type resourceKey string
+
+// loadResource returns a middleware that fetches the resource named by the :id
+// param with get and stores it in the context under key. When owner is set,
+// resources belonging to another user are reported as not found so that their
+// existence is not leaked.
+func loadResource[T any](app *Application, key resourceKey, get func(context.Context, uuid.UUID) (*T, error), owner func(*T) uuid.UUID) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := uuid.Parse(c.Params("id"))
+		if err != nil {
+			return app.badRequestResponse(c, err)
+		}
+
+		resource, err := getOwnedResource(c, id, get, owner)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.notFoundResponse(c, err)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+
+		c.Locals(key, resource)
+
+		return c.Next()
+	}
+}
+
+// getOwnedResource fetches a resource with get, reporting one that belongs to
+// another user as store.ErrNotFound when owner is set. Handlers use it for IDs
+// that arrive in a payload rather than the path.
+func getOwnedResource[T any](c *fiber.Ctx, id uuid.UUID, get func(context.Context, uuid.UUID) (*T, error), owner func(*T) uuid.UUID) (*T, error) {
+	resource, err := get(c.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner != nil && owner(resource) != getSelfFromContext(c).ID {
+		return nil, store.ErrNotFound
+	}
+
+	return resource, nil
+}
+
+func getResourceFromContext[T any](c *fiber.Ctx, key resourceKey) *T {
+	resource, _ := c.Locals(key).(*T)
+
+	return resource
+}
+
+func (app *Application) RateLimiterMiddleware(limiter ratelimiter.Limiter) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(c.IP()); !allow {
-				return app.rateLimitExceededResponse(c, retryAfter.String())
+			res, err := limiter.Allow(c.Context(), app.rateLimitKey(c))
+			if err != nil {
+				// fail open, an unreachable limiter store shouldn't take the API down with it
+				app.logger.Errorw("rate limiter error", "method", c.Method(), "path", c.Path(), "error", err)
+				return c.Next()
+			}
+
+			c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				return app.rateLimitExceededResponse(c, strconv.Itoa(ceilSeconds(res.RetryAfter)))
 			}
 		}
 		return c.Next()
 	}
 }
+
+// ceilSeconds rounds up so clients never retry before the limiter is ready.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey identifies who a request counts against. Authenticated users are
+// limited per account, wherever they connect from, and everyone else per IP.
+func (app *Application) rateLimitKey(c *fiber.Ctx) string {
+	if user := getSelfFromContext(c); user != nil {
+		return "user-" + user.ID.String()
+	}
+
+	// the limiter runs before AuthTokenMiddleware, so check the token here
+	parts := strings.Split(c.Get("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		if jwtToken, err := app.authenticator.ValidateToken(parts[1]); err == nil {
+			if sub, err := jwtToken.Claims.GetSubject(); err == nil && sub != "" {
+				return "user-" + sub
+			}
+		}
+	}
+
+	return "ip-" + c.IP()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type RejectFoodPayload struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// GetPendingFoods godoc
+//
+//	@Summary		Fetches the moderation queue
+//	@Description	Lists the public foods waiting for approval, oldest first
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Success		200		{object}	store.FoodPage
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/pending [get]
+func (app *Application) getPendingFoodsHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	page, err := app.store.Foods.GetPending(c.Context(), fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, page); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// ApproveFood godoc
+//
+//	@Summary		Approves a food
+//	@Description	Publishes a food to every user and marks it as verified
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		200	{object}	store.Food
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/{id}/approve [post]
+func (app *Application) approveFoodHandler(c *fiber.Ctx) error {
+	food := getModeratedFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if err := app.store.Foods.Approve(c.Context(), food, self.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrDuplicateBarcode):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, food); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// RejectFood godoc
+//
+//	@Summary		Rejects a food
+//	@Description	Keeps a food out of other users' searches, the reason is shown to the user who added it
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Food ID"
+//	@Param			payload	body		RejectFoodPayload	true	"Rejection payload"
+//	@Success		200		{object}	store.Food
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/{id}/reject [post]
+func (app *Application) rejectFoodHandler(c *fiber.Ctx) error {
+	var payload RejectFoodPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getModeratedFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if err := app.store.Foods.Reject(c.Context(), food, self.ID, payload.Reason); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, food); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// ModeratorUpdateFood godoc
+//
+//	@Summary		Corrects a food
+//	@Description	Edits any food's details without changing its moderation status, the changed fields are logged
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Food ID"
+//	@Param			payload	body		UpdateFoodPayload	true	"Food fields to change"
+//	@Success		200		{object}	store.Food
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/{id} [patch]
+func (app *Application) moderatorUpdateFoodHandler(c *fiber.Ctx) error {
+	var payload UpdateFoodPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getModeratedFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	updated := payload.apply(food)
+	if err := app.store.Foods.ModeratorEdit(c.Context(), food, updated, self.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("food was edited at the same time, try again"))
+		case errors.Is(err, store.ErrDuplicateBarcode):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, updated); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetFoodModerationHistory godoc
+//
+//	@Summary		Fetches a food's moderation history
+//	@Description	Lists every approval, rejection and edit made to a food by moderators, most recent first
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		200	{array}		store.FoodModerationAction
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/foods/{id}/moderation [get]
+func (app *Application) getFoodModerationHistoryHandler(c *fiber.Ctx) error {
+	food := getModeratedFoodFromContext(c)
+
+	actions, err := app.store.Foods.GetModerationHistory(c.Context(), food.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, actions); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const moderatedFoodCtxKey resourceKey = "moderatedFood"
+
+// moderatedFoodsContextMiddleware loads any food in the :id param regardless
+// of visibility, it must run after RequireRole.
+func (app *Application) moderatedFoodsContextMiddleware() fiber.Handler {
+	return loadResource(app, moderatedFoodCtxKey, app.store.Foods.GetByID, nil)
+}
+
+func getModeratedFoodFromContext(c *fiber.Ctx) *store.Food {
+	return getResourceFromContext[store.Food](c, moderatedFoodCtxKey)
+}
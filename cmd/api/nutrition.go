@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type UpsertNutritionProfilePayload struct {
+	Sex            string   `json:"sex" validate:"required,oneof=male female"`
+	BirthDate      string   `json:"birth_date" validate:"required,datetime=2006-01-02"`
+	HeightCm       float64  `json:"height_cm" validate:"required,gt=50,lt=300"`
+	WeightKg       float64  `json:"weight_kg" validate:"required,gt=20,lt=500"`
+	BodyFatPercent *float64 `json:"body_fat_percent" validate:"required_if=Formula katch_mcardle,omitempty,gt=2,lt=75"`
+	ActivityLevel  string   `json:"activity_level" validate:"required,oneof=sedentary light moderate active very_active"`
+	Goal           string   `json:"goal" validate:"required,oneof=lose maintain gain"`
+	GoalRateKg     float64  `json:"goal_rate_kg" validate:"gte=0,lte=1"`
+	Formula        string   `json:"bmr_formula" validate:"omitempty,oneof=mifflin_st_jeor katch_mcardle"`
+}
+
+type CreateNutritionTargetsPayload struct {
+	// Mode is auto to use the calculator, grams to give every macro in grams or
+	// percent to give them as a share of the calories.
+	Mode          string   `json:"mode" validate:"required,oneof=auto grams percent"`
+	EffectiveFrom string   `json:"effective_from" validate:"omitempty,datetime=2006-01-02"`
+	Calories      *float64 `json:"calories" validate:"omitempty,gte=800,lte=10000"`
+	Protein       *float64 `json:"protein" validate:"omitempty,gte=0"`
+	Carbs         *float64 `json:"carbs" validate:"omitempty,gte=0"`
+	Fat           *float64 `json:"fat" validate:"omitempty,gte=0"`
+	Fiber         *float64 `json:"fiber" validate:"omitempty,gte=0"`
+}
+
+type NutritionProfileWithEstimate struct {
+	*store.NutritionProfile
+	Estimate store.EnergyEstimate `json:"estimate"`
+}
+
+type NutritionTargetsHistory struct {
+	Current *store.NutritionTargets  `json:"current"`
+	History []store.NutritionTargets `json:"history"`
+}
+
+var (
+	errNoNutritionProfile = errors.New("set up a nutrition profile first, or give the calories and macros")
+	errMissingMacros      = errors.New("protein, carbs and fat are required")
+	errPercentagesSum     = errors.New("protein, carbs and fat percentages must add up to 100")
+	errTooFewCalories     = errors.New("protein, carbs and fat must add up to at least 800 kcal")
+)
+
+// GetSelfNutritionProfile godoc
+//
+//	@Summary		Fetches the user's nutrition profile
+//	@Description	Fetches the body stats and weight goal of the logged in user along with their estimated BMR, TDEE and goal calories
+//	@Tags			nutrition
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	NutritionProfileWithEstimate
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/nutrition/profile [get]
+func (app *Application) getSelfNutritionProfileHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	profile, err := app.store.Nutrition.GetProfile(c.Context(), self.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	estimate, err := profile.Estimate(time.Now())
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := NutritionProfileWithEstimate{NutritionProfile: profile, Estimate: estimate}
+	if err := app.jsonResponse(c, http.StatusOK, res); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UpsertSelfNutritionProfile godoc
+//
+//	@Summary		Sets the user's nutrition profile
+//	@Description	Creates or replaces the body stats and weight goal used to calculate calorie targets
+//	@Tags			nutrition
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpsertNutritionProfilePayload	true	"Nutrition profile payload"
+//	@Success		200		{object}	NutritionProfileWithEstimate
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/nutrition/profile [put]
+func (app *Application) upsertSelfNutritionProfileHandler(c *fiber.Ctx) error {
+	var payload UpsertNutritionProfilePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	profile := store.NutritionProfile{
+		UserID:         self.ID,
+		Sex:            store.Sex(payload.Sex),
+		BirthDate:      payload.BirthDate,
+		HeightCm:       payload.HeightCm,
+		WeightKg:       payload.WeightKg,
+		BodyFatPercent: payload.BodyFatPercent,
+		ActivityLevel:  store.ActivityLevel(payload.ActivityLevel),
+		Goal:           store.WeightGoal(payload.Goal),
+		GoalRateKg:     payload.GoalRateKg,
+		Formula:        store.MifflinStJeor,
+	}
+	if payload.Formula != "" {
+		profile.Formula = store.BMRFormula(payload.Formula)
+	}
+	if profile.Goal == store.GoalMaintain {
+		profile.GoalRateKg = 0
+	}
+
+	estimate, err := profile.Estimate(time.Now())
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := app.store.Nutrition.UpsertProfile(c.Context(), &profile); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := NutritionProfileWithEstimate{NutritionProfile: &profile, Estimate: estimate}
+	if err := app.jsonResponse(c, http.StatusOK, res); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetSelfNutritionTargets godoc
+//
+//	@Summary		Fetches the user's nutrition targets
+//	@Description	Fetches the calorie and macro targets in effect today and every target set before, newest first
+//	@Tags			nutrition
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	NutritionTargetsHistory
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/nutrition/targets [get]
+func (app *Application) getSelfNutritionTargetsHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	history, err := app.store.Nutrition.GetTargetsHistory(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	res := NutritionTargetsHistory{
+		Current: store.TargetsOn(history, time.Now().UTC().Format(time.DateOnly)),
+		History: history,
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, res); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// CreateSelfNutritionTargets godoc
+//
+//	@Summary		Sets new nutrition targets
+//	@Description	Sets calorie and macro targets from a date on, either calculated from the nutrition profile or given in grams or percentages. Earlier targets are kept for the days they applied to
+//	@Tags			nutrition
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateNutritionTargetsPayload	true	"Nutrition targets payload"
+//	@Success		201		{object}	store.NutritionTargets
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/nutrition/targets [post]
+func (app *Application) createSelfNutritionTargetsHandler(c *fiber.Ctx) error {
+	var payload CreateNutritionTargetsPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	profile, err := app.store.Nutrition.GetProfile(c.Context(), self.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return app.internalServerError(c, err)
+	}
+
+	targets := store.NutritionTargets{
+		UserID:        self.ID,
+		EffectiveFrom: payload.EffectiveFrom,
+	}
+	if targets.EffectiveFrom == "" {
+		targets.EffectiveFrom = time.Now().UTC().Format(time.DateOnly)
+	}
+
+	targets.NutritionTotals, targets.Percentages, err = calculateTargets(payload, profile)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := app.store.Nutrition.CreateTargets(c.Context(), &targets); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, targets); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// calculateTargets works out the gram targets of the payload. profile is nil
+// when the user hasn't set one up, in which case nothing can be calculated.
+func calculateTargets(payload CreateNutritionTargetsPayload, profile *store.NutritionProfile) (store.NutritionTotals, *store.MacroSplit, error) {
+	calories := 0.0
+	if payload.Calories != nil {
+		calories = *payload.Calories
+	} else if profile != nil {
+		estimate, err := profile.Estimate(time.Now())
+		if err != nil {
+			return store.NutritionTotals{}, nil, err
+		}
+
+		calories = estimate.TargetCalories
+	}
+
+	var totals store.NutritionTotals
+	var split *store.MacroSplit
+
+	switch payload.Mode {
+	case "auto":
+		if profile == nil {
+			return store.NutritionTotals{}, nil, errNoNutritionProfile
+		}
+
+		totals = store.SuggestMacros(calories, profile.WeightKg, profile.Goal)
+	case "grams":
+		if payload.Protein == nil || payload.Carbs == nil || payload.Fat == nil {
+			return store.NutritionTotals{}, nil, errMissingMacros
+		}
+
+		totals = store.NutritionTotals{
+			Calories: calories,
+			Protein:  *payload.Protein,
+			Carbs:    *payload.Carbs,
+			Fat:      *payload.Fat,
+		}
+		if payload.Calories == nil {
+			// the macros are the whole picture, so they decide the calories
+			totals.Calories = *payload.Protein*4 + *payload.Carbs*4 + *payload.Fat*9
+			if totals.Calories < 800 {
+				return store.NutritionTotals{}, nil, errTooFewCalories
+			}
+		}
+		totals.Fiber = store.FiberTarget(totals.Calories)
+	case "percent":
+		if payload.Protein == nil || payload.Carbs == nil || payload.Fat == nil {
+			return store.NutritionTotals{}, nil, errMissingMacros
+		}
+
+		if calories == 0 {
+			return store.NutritionTotals{}, nil, errNoNutritionProfile
+		}
+
+		split = &store.MacroSplit{Protein: *payload.Protein, Carbs: *payload.Carbs, Fat: *payload.Fat}
+		if math.Abs(split.Protein+split.Carbs+split.Fat-100) > 1 {
+			return store.NutritionTotals{}, nil, errPercentagesSum
+		}
+
+		totals = store.MacrosFromPercentages(calories, *split)
+	}
+
+	if payload.Fiber != nil {
+		totals.Fiber = *payload.Fiber
+	}
+
+	return totals, split, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+func TestCalculateTargets(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+
+	bodyFat := 20.0
+	// Katch-McArdle so the estimate doesn't depend on today's date, lean mass
+	// of 64 kg gives a TDEE of 2103 kcal
+	maintain := &store.NutritionProfile{
+		WeightKg:       80,
+		BodyFatPercent: &bodyFat,
+		ActivityLevel:  store.ActivitySedentary,
+		Goal:           store.GoalMaintain,
+		Formula:        store.KatchMcArdle,
+	}
+	// a TDEE of 1377 kcal and a 1 kg/week deficit would leave 277 kcal
+	aggressive := &store.NutritionProfile{
+		WeightKg:       45,
+		BodyFatPercent: &bodyFat,
+		ActivityLevel:  store.ActivitySedentary,
+		Goal:           store.GoalLose,
+		GoalRateKg:     1,
+		Formula:        store.KatchMcArdle,
+	}
+
+	tests := []struct {
+		name      string
+		payload   CreateNutritionTargetsPayload
+		profile   *store.NutritionProfile
+		want      store.NutritionTotals
+		wantSplit *store.MacroSplit
+		wantErr   error
+	}{
+		{
+			name:    "auto from the estimate",
+			payload: CreateNutritionTargetsPayload{Mode: "auto"},
+			profile: maintain,
+			want:    store.NutritionTotals{Calories: 2103, Protein: 144, Carbs: 251, Fat: 58, Fiber: 29},
+		},
+		{
+			name:    "auto keeps aggressive goals above the floor",
+			payload: CreateNutritionTargetsPayload{Mode: "auto"},
+			profile: aggressive,
+			want:    store.SuggestMacros(store.MinTargetCalories, 45, store.GoalLose),
+		},
+		{
+			name:    "auto with given calories",
+			payload: CreateNutritionTargetsPayload{Mode: "auto", Calories: float(2000)},
+			profile: maintain,
+			want:    store.SuggestMacros(2000, 80, store.GoalMaintain),
+		},
+		{
+			name:    "auto without a profile",
+			payload: CreateNutritionTargetsPayload{Mode: "auto", Calories: float(2000)},
+			wantErr: errNoNutritionProfile,
+		},
+		{
+			name:    "grams decide the calories",
+			payload: CreateNutritionTargetsPayload{Mode: "grams", Protein: float(150), Carbs: float(200), Fat: float(60)},
+			want:    store.NutritionTotals{Calories: 1940, Protein: 150, Carbs: 200, Fat: 60, Fiber: 27},
+		},
+		{
+			name:    "grams with given calories and fiber",
+			payload: CreateNutritionTargetsPayload{Mode: "grams", Calories: float(2500), Protein: float(150), Carbs: float(200), Fat: float(60), Fiber: float(35)},
+			want:    store.NutritionTotals{Calories: 2500, Protein: 150, Carbs: 200, Fat: 60, Fiber: 35},
+		},
+		{
+			name:    "grams adding up to too few calories",
+			payload: CreateNutritionTargetsPayload{Mode: "grams", Protein: float(0), Carbs: float(0), Fat: float(0)},
+			wantErr: errTooFewCalories,
+		},
+		{
+			name:    "grams missing a macro",
+			payload: CreateNutritionTargetsPayload{Mode: "grams", Protein: float(150), Carbs: float(200)},
+			wantErr: errMissingMacros,
+		},
+		{
+			name:      "percent of given calories",
+			payload:   CreateNutritionTargetsPayload{Mode: "percent", Calories: float(2000), Protein: float(30), Carbs: float(40), Fat: float(30)},
+			want:      store.NutritionTotals{Calories: 2000, Protein: 150, Carbs: 200, Fat: 67, Fiber: 28},
+			wantSplit: &store.MacroSplit{Protein: 30, Carbs: 40, Fat: 30},
+		},
+		{
+			name:    "percent not adding up",
+			payload: CreateNutritionTargetsPayload{Mode: "percent", Calories: float(2000), Protein: float(30), Carbs: float(40), Fat: float(20)},
+			wantErr: errPercentagesSum,
+		},
+		{
+			name:    "percent without calories or a profile",
+			payload: CreateNutritionTargetsPayload{Mode: "percent", Protein: float(30), Carbs: float(40), Fat: float(30)},
+			wantErr: errNoNutritionProfile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, split, err := calculateTargets(tt.payload, tt.profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("calculateTargets() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("calculateTargets() = %+v, want %+v", got, tt.want)
+			}
+			if (split == nil) != (tt.wantSplit == nil) || (split != nil && *split != *tt.wantSplit) {
+				t.Errorf("calculateTargets() split = %+v, want %+v", split, tt.wantSplit)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+// CreateFoodPortionPayload defines Name as Amount of Unit, e.g. 1 slice = 28 g.
+// Unit is a unit of mass or volume, or the food's serving unit.
+type CreateFoodPortionPayload struct {
+	Name   string  `json:"name" validate:"required,max=50"`
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Unit   string  `json:"unit" validate:"required,max=50"`
+}
+
+// GetFoodPortions godoc
+//
+//	@Summary		Fetches a food's portions
+//	@Description	Lists the household measures a food can be logged in besides units of mass and volume
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Food ID"
+//	@Success		200	{array}		store.FoodPortion
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/portions [get]
+func (app *Application) getFoodPortionsHandler(c *fiber.Ctx) error {
+	food := getFoodFromContext(c)
+
+	portions, err := app.store.Foods.GetPortions(c.Context(), food.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, portions); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// CreateFoodPortion godoc
+//
+//	@Summary		Adds a portion to a food
+//	@Description	Defines a household measure, e.g. 1 slice = 28 g, that meal entries of the food can be logged in. Only the food's creator or an admin can add one
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"Food ID"
+//	@Param			payload	body		CreateFoodPortionPayload	true	"Portion payload"
+//	@Success		201		{object}	store.FoodPortion
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/portions [post]
+func (app *Application) createFoodPortionHandler(c *fiber.Ctx) error {
+	var payload CreateFoodPortionPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if !canEditFood(self, food) {
+		return app.forbiddenResponse(c)
+	}
+
+	// a portion can't shadow a unit the food already converts from
+	measure := food.Measure(nil)
+	if _, ok := units.Lookup(payload.Name); ok || measure.Supports(payload.Name) {
+		return app.badRequestResponse(c, errors.New("portion name is already a unit"))
+	}
+
+	if !measure.Supports(payload.Unit) {
+		return app.badRequestResponse(c, units.ErrUnsupportedUnit)
+	}
+
+	portion := store.FoodPortion{
+		FoodID: food.ID,
+		Name:   payload.Name,
+		Amount: payload.Amount,
+		Unit:   payload.Unit,
+	}
+
+	if err := app.store.Foods.CreatePortion(c.Context(), &portion); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicatePortion):
+			return app.conflictResponse(c, err)
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, portion); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DeleteFoodPortion godoc
+//
+//	@Summary		Deletes a food's portion
+//	@Description	Removes a portion no meal entry or template is logged in. Only the food's creator or an admin can remove one
+//	@Tags			foods
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string	true	"Food ID"
+//	@Param			portionID	path		string	true	"Portion ID"
+//	@Success		204			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/food/{id}/portions/{portionID} [delete]
+func (app *Application) deleteFoodPortionHandler(c *fiber.Ctx) error {
+	portionID, err := uuid.Parse(c.Params("portionID"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	food := getFoodFromContext(c)
+	self := getSelfFromContext(c)
+
+	if !canEditFood(self, food) {
+		return app.forbiddenResponse(c)
+	}
+
+	if err := app.store.Foods.DeletePortion(c.Context(), food.ID, portionID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		case errors.Is(err, store.ErrPortionInUse):
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+func canEditFood(user *store.User, food *store.Food) bool {
+	isOwner := food.UserID != nil && *food.UserID == user.ID
+	return isOwner || user.Role.Level >= store.RoleLevelAdmin
+}
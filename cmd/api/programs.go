@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type CreateProgramPayload struct {
+	Name            string   `json:"name" validate:"required,max=255"`
+	Description     string   `json:"description" validate:"max=2000"`
+	Weeks           int      `json:"weeks" validate:"required,gte=1,lte=52"`
+	WeightIncrement float64  `json:"weight_increment" validate:"gte=0"`
+	DeloadEvery     int      `json:"deload_every" validate:"gte=0"`
+	DeloadPercent   *float64 `json:"deload_percent" validate:"omitempty,gt=0,lte=100"`
+	RoutineIDs      []string `json:"routine_ids" validate:"required,min=1,max=7,dive,uuid"`
+}
+
+type ProgramWorkout struct {
+	Workout    *store.Workout           `json:"workout"`
+	Week       int                      `json:"week"`
+	Day        int                      `json:"day"`
+	Deload     bool                     `json:"deload"`
+	Enrollment *store.ProgramEnrollment `json:"enrollment"`
+}
+
+// CreateProgram godoc
+//
+//	@Summary		Creates a training program
+//	@Description	Creates a multi-week program whose days are the given routines, in order. Requires a subscriber role
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateProgramPayload	true	"Program payload"
+//	@Success		201		{object}	store.Program
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/programs [post]
+func (app *Application) createProgramHandler(c *fiber.Ctx) error {
+	var payload CreateProgramPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	program := store.Program{
+		UserID:          self.ID,
+		Name:            payload.Name,
+		Description:     payload.Description,
+		Weeks:           payload.Weeks,
+		WeightIncrement: payload.WeightIncrement,
+		DeloadEvery:     payload.DeloadEvery,
+		DeloadPercent:   100,
+	}
+	if payload.DeloadPercent != nil {
+		program.DeloadPercent = *payload.DeloadPercent
+	}
+
+	for i, id := range payload.RoutineIDs {
+		// programs may only be built from the coach's own routines
+		routine, err := getOwnedResource(c, uuid.MustParse(id), app.store.Routines.GetByID, routineOwner)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return app.badRequestResponse(c, store.ErrUnknownRoutine)
+			default:
+				return app.internalServerError(c, err)
+			}
+		}
+
+		program.Days = append(program.Days, store.ProgramDay{
+			DayNumber:   i + 1,
+			RoutineID:   routine.ID,
+			RoutineName: routine.Name,
+		})
+	}
+
+	if err := app.store.Programs.Create(c.Context(), &program); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownRoutine):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, program); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetProgram godoc
+//
+//	@Summary		Fetches a training program
+//	@Description	Fetches a program and its days by ID
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Program ID"
+//	@Success		200	{object}	store.Program
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/programs/{id} [get]
+func (app *Application) getProgramHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	program, err := app.store.Programs.GetByID(c.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, program); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// EnrollProgram godoc
+//
+//	@Summary		Enrolls in a training program
+//	@Description	Starts following a program from week 1, day 1
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Program ID"
+//	@Success		201	{object}	store.ProgramEnrollment
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/programs/{id}/enroll [post]
+func (app *Application) enrollProgramHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	program, err := app.store.Programs.GetByID(c.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	self := getSelfFromContext(c)
+
+	enrollment := store.ProgramEnrollment{
+		UserID:    self.ID,
+		ProgramID: program.ID,
+	}
+
+	if err := app.store.Programs.Enroll(c.Context(), &enrollment); err != nil {
+		switch err {
+		case store.ErrConflict:
+			return app.conflictResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, enrollment); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetProgramEnrollment godoc
+//
+//	@Summary		Fetches the user's progress in a program
+//	@Description	Fetches which week and day of the program the user is on
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Program ID"
+//	@Success		200	{object}	store.ProgramEnrollment
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/programs/{id}/enrollment [get]
+func (app *Application) getProgramEnrollmentHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	enrollment, err := app.store.Programs.GetEnrollment(c.Context(), self.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, enrollment); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// StartProgramWorkout godoc
+//
+//	@Summary		Starts the next program workout
+//	@Description	Creates a workout from the user's current program day, applying the program's progression, and moves on to the next day
+//	@Tags			programs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Program ID"
+//	@Success		201	{object}	ProgramWorkout
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/programs/{id}/workouts [post]
+func (app *Application) startProgramWorkoutHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	enrollment, err := app.store.Programs.GetEnrollment(c.Context(), self.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	program, err := app.store.Programs.GetByID(c.Context(), id)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	week, day := enrollment.CurrentWeek, enrollment.CurrentDay
+	if day > len(program.Days) {
+		return app.internalServerError(c, fmt.Errorf("program %s has no day %d", program.ID, day))
+	}
+
+	routine, err := app.store.Routines.GetByID(c.Context(), program.Days[day-1].RoutineID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	workout := store.Workout{
+		UserID:    self.ID,
+		Name:      fmt.Sprintf("%s - Week %d Day %d", program.Name, week, day),
+		Notes:     routine.Notes,
+		StartedAt: time.Now().Format(time.RFC3339),
+		Sets: routine.Sets(func(weight float64) float64 {
+			return program.TargetWeight(weight, week)
+		}),
+	}
+
+	if err := app.store.Programs.StartWorkout(c.Context(), program, enrollment, &workout); err != nil {
+		switch {
+		case errors.Is(err, store.ErrProgramCompleted):
+			return app.conflictResponse(c, err)
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("program workout was started at the same time, try again"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	res := ProgramWorkout{
+		Workout:    &workout,
+		Week:       week,
+		Day:        day,
+		Deload:     program.IsDeloadWeek(week),
+		Enrollment: enrollment,
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, res); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+// RecipeIngredientPayload gives Amount of the food in Unit, a unit of mass or
+// volume, one of the food's portions or "serving". Unit defaults to the food's
+// serving unit.
+type RecipeIngredientPayload struct {
+	FoodID string  `json:"food_id" validate:"required,uuid"`
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Unit   string  `json:"unit" validate:"max=50"`
+}
+
+type RecipePayload struct {
+	Name        string                    `json:"name" validate:"required,max=255"`
+	Notes       string                    `json:"notes" validate:"max=2000"`
+	Servings    float64                   `json:"servings" validate:"required,gt=0,lte=1000"`
+	Ingredients []RecipeIngredientPayload `json:"ingredients" validate:"required,min=1,max=100,dive"`
+}
+
+// CreateRecipe godoc
+//
+//	@Summary		Creates a recipe
+//	@Description	Saves a dish made of foods, with the amount of each in a unit the food converts from and the number of servings it makes
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RecipePayload	true	"Recipe payload"
+//	@Success		201		{object}	store.Recipe
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recipes [post]
+func (app *Application) createRecipeHandler(c *fiber.Ctx) error {
+	var payload RecipePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	recipe := store.Recipe{UserID: self.ID}
+	payload.apply(&recipe)
+
+	if err := app.checkIngredients(c, &recipe, nil); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownFood), errors.Is(err, units.ErrUnsupportedUnit):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.store.Recipes.Create(c.Context(), &recipe); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownFood):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.respondWithRecipe(c, http.StatusCreated, recipe.ID)
+}
+
+// GetRecipes godoc
+//
+//	@Summary		Fetches the user's recipes
+//	@Description	Lists the logged in user's recipes with their nutrition per serving
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		store.Recipe
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recipes [get]
+func (app *Application) getRecipesHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	recipes, err := app.store.Recipes.GetByUser(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, recipes); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetRecipe godoc
+//
+//	@Summary		Fetches a recipe
+//	@Description	Fetches a recipe with its ingredients and nutrition per serving
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Recipe ID"
+//	@Success		200	{object}	store.Recipe
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recipes/{id} [get]
+func (app *Application) getRecipeHandler(c *fiber.Ctx) error {
+	recipe := getRecipeFromContext(c)
+
+	if err := app.jsonResponse(c, http.StatusOK, recipe); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UpdateRecipe godoc
+//
+//	@Summary		Updates a recipe
+//	@Description	Replaces a recipe's details and ingredients. Entries already logged from it keep the ingredients and servings they were logged with
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string			true	"Recipe ID"
+//	@Param			payload	body		RecipePayload	true	"Recipe payload"
+//	@Success		200		{object}	store.Recipe
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recipes/{id} [put]
+func (app *Application) updateRecipeHandler(c *fiber.Ctx) error {
+	var payload RecipePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	recipe := getRecipeFromContext(c)
+
+	referenced := make(map[uuid.UUID]bool, len(recipe.Ingredients))
+	for _, ingredient := range recipe.Ingredients {
+		referenced[ingredient.FoodID] = true
+	}
+
+	payload.apply(recipe)
+
+	if err := app.checkIngredients(c, recipe, referenced); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownFood), errors.Is(err, units.ErrUnsupportedUnit):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.store.Recipes.Update(c.Context(), recipe); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownFood):
+			return app.badRequestResponse(c, err)
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	return app.respondWithRecipe(c, http.StatusOK, recipe.ID)
+}
+
+// DeleteRecipe godoc
+//
+//	@Summary		Deletes a recipe
+//	@Description	Deletes a recipe by ID, recipes logged in the diary can't be deleted
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Recipe ID"
+//	@Success		204	{object}	string
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/recipes/{id} [delete]
+func (app *Application) deleteRecipeHandler(c *fiber.Ctx) error {
+	recipe := getRecipeFromContext(c)
+
+	if err := app.store.Recipes.Delete(c.Context(), recipe.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("recipe is logged in the diary"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+func (payload RecipePayload) apply(recipe *store.Recipe) {
+	recipe.Name = payload.Name
+	recipe.Notes = payload.Notes
+	recipe.Servings = payload.Servings
+	recipe.Ingredients = nil
+	for i, ingredient := range payload.Ingredients {
+		recipe.Ingredients = append(recipe.Ingredients, store.RecipeIngredient{
+			FoodID:   uuid.MustParse(ingredient.FoodID),
+			Position: i,
+			Amount:   ingredient.Amount,
+			Unit:     ingredient.Unit,
+		})
+	}
+}
+
+// checkIngredients makes sure the user can see every ingredient's food and
+// that its unit converts, and points merged foods at the food they were
+// merged into. Foods in referenced are already in the recipe and resolve even
+// if the user can no longer see them.
+func (app *Application) checkIngredients(c *fiber.Ctx, recipe *store.Recipe, referenced map[uuid.UUID]bool) error {
+	for i := range recipe.Ingredients {
+		ingredient := &recipe.Ingredients[i]
+
+		var food *store.Food
+		var err error
+		if referenced[ingredient.FoodID] {
+			food, err = app.getReferencedFood(c, ingredient.FoodID)
+		} else {
+			food, err = app.getVisibleFood(c, ingredient.FoodID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ingredient.Unit == "" {
+			ingredient.Unit = food.ServingUnit
+		} else if err := app.checkFoodUnit(c, food, ingredient.Unit); err != nil {
+			return err
+		}
+
+		ingredient.FoodID = food.ID
+	}
+
+	return nil
+}
+
+// respondWithRecipe reloads a saved recipe so the response carries the food
+// names and the nutrition worked out from them.
+func (app *Application) respondWithRecipe(c *fiber.Ctx, status int, id uuid.UUID) error {
+	recipe, err := app.store.Recipes.GetByID(c.Context(), id)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, status, recipe); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const recipeCtxKey resourceKey = "recipe"
+
+func (app *Application) recipesContextMiddleware() fiber.Handler {
+	return loadResource(app, recipeCtxKey, app.store.Recipes.GetByID, recipeOwner)
+}
+
+// recipeOwner reports who owns a recipe, recipes are private to their owner.
+func recipeOwner(recipe *store.Recipe) uuid.UUID {
+	return recipe.UserID
+}
+
+func getRecipeFromContext(c *fiber.Ctx) *store.Recipe {
+	return getResourceFromContext[store.Recipe](c, recipeCtxKey)
+}
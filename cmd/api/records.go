@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+// GetSelfRecords godoc
+//
+//	@Summary		Fetches the user's personal records
+//	@Description	Fetches the heaviest weight, best estimated 1RM, best session volume and rep records per exercise
+//	@Tags			records
+//	@Accept			json
+//	@Produce		json
+//	@Param			formula	query		string	false	"One rep max formula (epley, brzycki)"
+//	@Success		200		{array}		store.ExerciseRecords
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/records [get]
+func (app *Application) getSelfRecordsHandler(c *fiber.Ctx) error {
+	formula, err := store.ParseOneRepMaxFormula(c.Query("formula"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	records, err := app.store.Records.GetByUser(c.Context(), self.ID, formula)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, records); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetSelfExerciseHistory godoc
+//
+//	@Summary		Fetches the user's history for an exercise
+//	@Description	Fetches a per-session summary of every workout the exercise was logged in
+//	@Tags			records
+//	@Accept			json
+//	@Produce		json
+//	@Param			exerciseID	path		string	true	"Exercise ID"
+//	@Param			formula		query		string	false	"One rep max formula (epley, brzycki)"
+//	@Success		200			{array}		store.ExerciseSession
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/self/records/{exerciseID} [get]
+func (app *Application) getSelfExerciseHistoryHandler(c *fiber.Ctx) error {
+	exerciseID, err := uuid.Parse(c.Params("exerciseID"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	formula, err := store.ParseOneRepMaxFormula(c.Query("formula"))
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	history, err := app.store.Records.GetExerciseHistory(c.Context(), self.ID, exerciseID, formula)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, history); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
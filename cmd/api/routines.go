@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type RoutineExercisePayload struct {
+	ExerciseID   string   `json:"exercise_id" validate:"required,uuid"`
+	TargetSets   int      `json:"target_sets" validate:"required,gte=1,lte=20"`
+	TargetReps   int      `json:"target_reps" validate:"required,gte=1,lte=100"`
+	TargetWeight *float64 `json:"target_weight" validate:"omitempty,gte=0"`
+}
+
+type CreateRoutinePayload struct {
+	Name      string                   `json:"name" validate:"required,max=255"`
+	Notes     string                   `json:"notes" validate:"max=2000"`
+	Exercises []RoutineExercisePayload `json:"exercises" validate:"required,min=1,dive"`
+}
+
+// CreateRoutine godoc
+//
+//	@Summary		Creates a routine
+//	@Description	Saves an ordered list of exercises with target sets, reps and weight
+//	@Tags			routines
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateRoutinePayload	true	"Routine payload"
+//	@Success		201		{object}	store.Routine
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/routines [post]
+func (app *Application) createRoutineHandler(c *fiber.Ctx) error {
+	var payload CreateRoutinePayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	routine := store.Routine{
+		UserID: self.ID,
+		Name:   payload.Name,
+		Notes:  payload.Notes,
+	}
+	for i, exercise := range payload.Exercises {
+		routine.Exercises = append(routine.Exercises, store.RoutineExercise{
+			ExerciseID:   uuid.MustParse(exercise.ExerciseID),
+			Position:     i,
+			TargetSets:   exercise.TargetSets,
+			TargetReps:   exercise.TargetReps,
+			TargetWeight: exercise.TargetWeight,
+		})
+	}
+
+	if err := app.store.Routines.Create(c.Context(), &routine); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownExercise):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, routine); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetRoutines godoc
+//
+//	@Summary		Fetches the user's routines
+//	@Description	Lists the logged in user's saved routines
+//	@Tags			routines
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		store.Routine
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/routines [get]
+func (app *Application) getRoutinesHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	routines, err := app.store.Routines.GetByUser(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, routines); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetRoutine godoc
+//
+//	@Summary		Fetches a routine
+//	@Description	Fetches a routine and its exercises by ID
+//	@Tags			routines
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Routine ID"
+//	@Success		200	{object}	store.Routine
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/routines/{id} [get]
+func (app *Application) getRoutineHandler(c *fiber.Ctx) error {
+	routine := getRoutineFromContext(c)
+
+	if err := app.jsonResponse(c, http.StatusOK, routine); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DeleteRoutine godoc
+//
+//	@Summary		Deletes a routine
+//	@Description	Deletes a routine by ID, unless a program still uses it
+//	@Tags			routines
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Routine ID"
+//	@Success		204	{object}	string
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/routines/{id} [delete]
+func (app *Application) deleteRoutineHandler(c *fiber.Ctx) error {
+	routine := getRoutineFromContext(c)
+
+	if err := app.store.Routines.Delete(c.Context(), routine.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			return app.conflictResponse(c, errors.New("routine is used by a program"))
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// StartRoutine godoc
+//
+//	@Summary		Starts a workout from a routine
+//	@Description	Creates a workout pre-filled with the routine's target sets
+//	@Tags			routines
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Routine ID"
+//	@Success		201	{object}	store.Workout
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/routines/{id}/start [post]
+func (app *Application) startRoutineHandler(c *fiber.Ctx) error {
+	routine := getRoutineFromContext(c)
+
+	workout := store.Workout{
+		UserID:    routine.UserID,
+		Name:      routine.Name,
+		Notes:     routine.Notes,
+		StartedAt: time.Now().Format(time.RFC3339),
+		Sets:      routine.Sets(func(weight float64) float64 { return weight }),
+	}
+
+	if err := app.store.Workouts.Create(c.Context(), &workout); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusCreated, workout); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const routineCtxKey resourceKey = "routine"
+
+func (app *Application) routinesContextMiddleware() fiber.Handler {
+	return loadResource(app, routineCtxKey, app.store.Routines.GetByID, routineOwner)
+}
+
+// routineOwner reports who owns a routine, routines are private to their owner.
+func routineOwner(routine *store.Routine) uuid.UUID {
+	return routine.UserID
+}
+
+func getRoutineFromContext(c *fiber.Ctx) *store.Routine {
+	return getResourceFromContext[store.Routine](c, routineCtxKey)
+}
@@ -5,13 +5,17 @@ import (
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/zondaf12/workout-app-backend/internal/store"
 )
 
 type selfKey string
 
-const selfCtxKey selfKey = "self"
+const (
+	selfCtxKey   selfKey = "self"
+	claimsCtxKey selfKey = "claims"
+)
 
 // GetUser godoc
 //
@@ -189,3 +193,90 @@ func getSelfFromContext(c *fiber.Ctx) *store.User {
 
 	return self
 }
+
+func getClaimsFromContext(c *fiber.Ctx) jwt.MapClaims {
+	claims, _ := c.Locals(claimsCtxKey).(jwt.MapClaims)
+
+	return claims
+}
+
+type UpdatePrivacyPayload struct {
+	ShareWorkouts *bool `json:"share_workouts"`
+	ShareRecords  *bool `json:"share_records"`
+}
+
+// GetSelfPrivacy godoc
+//
+//	@Summary		Fetches the user's privacy settings
+//	@Description	Fetches which activity the logged in user shares with followers
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	store.PrivacySettings
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/self/privacy [get]
+func (app *Application) getSelfPrivacyHandler(c *fiber.Ctx) error {
+	self := getSelfFromContext(c)
+
+	settings, err := app.store.Users.GetPrivacy(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, settings); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UpdateSelfPrivacy godoc
+//
+//	@Summary		Updates the user's privacy settings
+//	@Description	Updates which activity the logged in user shares with followers
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePrivacyPayload	true	"Privacy payload"
+//	@Success		200		{object}	store.PrivacySettings
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//
+//	@Security		ApiKeyAuth
+//
+//	@Router			/users/self/privacy [patch]
+func (app *Application) updateSelfPrivacyHandler(c *fiber.Ctx) error {
+	var payload UpdatePrivacyPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	settings, err := app.store.Users.GetPrivacy(c.Context(), self.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if payload.ShareWorkouts != nil {
+		settings.ShareWorkouts = *payload.ShareWorkouts
+	}
+	if payload.ShareRecords != nil {
+		settings.ShareRecords = *payload.ShareRecords
+	}
+
+	if err := app.store.Users.UpdatePrivacy(c.Context(), self.ID, settings); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, settings); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type WorkoutSetPayload struct {
+	ExerciseID      string   `json:"exercise_id" validate:"required,uuid"`
+	Reps            *int     `json:"reps" validate:"omitempty,gte=0"`
+	Weight          *float64 `json:"weight" validate:"omitempty,gte=0"`
+	RPE             *float64 `json:"rpe" validate:"omitempty,gte=1,lte=10"`
+	DurationSeconds *int     `json:"duration_seconds" validate:"omitempty,gte=0"`
+	DistanceMeters  *float64 `json:"distance_meters" validate:"omitempty,gte=0"`
+}
+
+type CreateWorkoutPayload struct {
+	Name      string              `json:"name" validate:"required,max=255"`
+	Notes     string              `json:"notes" validate:"max=2000"`
+	StartedAt string              `json:"started_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	EndedAt   *string             `json:"ended_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Sets      []WorkoutSetPayload `json:"sets" validate:"omitempty,dive"`
+}
+
+type UpdateWorkoutPayload struct {
+	Name      *string             `json:"name" validate:"omitempty,max=255"`
+	Notes     *string             `json:"notes" validate:"omitempty,max=2000"`
+	StartedAt *string             `json:"started_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EndedAt   *string             `json:"ended_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Sets      []WorkoutSetPayload `json:"sets" validate:"omitempty,dive"`
+}
+
+// toWorkoutSets converts the payload sets into store sets, ordered as sent.
+// A nil payload stays nil so updates can tell "not sent" from "no sets".
+func toWorkoutSets(payload []WorkoutSetPayload) []store.WorkoutSet {
+	if payload == nil {
+		return nil
+	}
+
+	sets := make([]store.WorkoutSet, 0, len(payload))
+	for i, p := range payload {
+		sets = append(sets, store.WorkoutSet{
+			ExerciseID:      uuid.MustParse(p.ExerciseID),
+			Position:        i,
+			Reps:            p.Reps,
+			Weight:          p.Weight,
+			RPE:             p.RPE,
+			DurationSeconds: p.DurationSeconds,
+			DistanceMeters:  p.DistanceMeters,
+		})
+	}
+
+	return sets
+}
+
+// CreateWorkout godoc
+//
+//	@Summary		Creates a workout
+//	@Description	Logs a workout session with its sets
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateWorkoutPayload	true	"Workout payload"
+//	@Success		201		{object}	store.Workout
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/workouts [post]
+func (app *Application) createWorkoutHandler(c *fiber.Ctx) error {
+	var payload CreateWorkoutPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	workout := store.Workout{
+		UserID:    self.ID,
+		Name:      payload.Name,
+		Notes:     payload.Notes,
+		StartedAt: payload.StartedAt,
+		EndedAt:   payload.EndedAt,
+		Sets:      toWorkoutSets(payload.Sets),
+	}
+
+	if err := app.store.Workouts.Create(c.Context(), &workout); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownExercise):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	app.publishWorkoutActivity(c, &workout)
+
+	if err := app.jsonResponse(c, http.StatusCreated, workout); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetWorkouts godoc
+//
+//	@Summary		Fetches the user's workouts
+//	@Description	Lists the logged in user's workouts, newest first
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from the previous page"
+//	@Success		200		{object}	store.WorkoutPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/workouts [get]
+func (app *Application) getWorkoutsHandler(c *fiber.Ctx) error {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+	}
+
+	fq, err := fq.Parse(c)
+	if err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	self := getSelfFromContext(c)
+
+	page, err := app.store.Workouts.GetByUser(c.Context(), self.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			return app.badRequestResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, page); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// GetWorkout godoc
+//
+//	@Summary		Fetches a workout
+//	@Description	Fetches a workout and its sets by ID
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Workout ID"
+//	@Success		200	{object}	store.Workout
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/workouts/{id} [get]
+func (app *Application) getWorkoutHandler(c *fiber.Ctx) error {
+	workout := getWorkoutFromContext(c)
+
+	if err := app.jsonResponse(c, http.StatusOK, workout); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// UpdateWorkout godoc
+//
+//	@Summary		Updates a workout
+//	@Description	Updates a workout by ID, replacing its sets when they are sent
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Workout ID"
+//	@Param			payload	body		UpdateWorkoutPayload	true	"Workout payload"
+//	@Success		200		{object}	store.Workout
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/workouts/{id} [patch]
+func (app *Application) updateWorkoutHandler(c *fiber.Ctx) error {
+	var payload UpdateWorkoutPayload
+	if err := readJSON(c, &payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		return app.badRequestResponse(c, err)
+	}
+
+	workout := getWorkoutFromContext(c)
+
+	wasFinished := workout.EndedAt != nil
+
+	if payload.Name != nil {
+		workout.Name = *payload.Name
+	}
+	if payload.Notes != nil {
+		workout.Notes = *payload.Notes
+	}
+	if payload.StartedAt != nil {
+		workout.StartedAt = *payload.StartedAt
+	}
+	if payload.EndedAt != nil {
+		workout.EndedAt = payload.EndedAt
+	}
+	workout.Sets = toWorkoutSets(payload.Sets)
+
+	if err := app.store.Workouts.Update(c.Context(), workout); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnknownExercise):
+			return app.badRequestResponse(c, err)
+		case errors.Is(err, store.ErrNotFound):
+			return app.notFoundResponse(c, err)
+		default:
+			return app.internalServerError(c, err)
+		}
+	}
+
+	updated, err := app.store.Workouts.GetByID(c.Context(), workout.ID)
+	if err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if !wasFinished {
+		app.publishWorkoutActivity(c, updated)
+	}
+
+	if err := app.jsonResponse(c, http.StatusOK, updated); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+// DeleteWorkout godoc
+//
+//	@Summary		Deletes a workout
+//	@Description	Deletes a workout and its sets by ID
+//	@Tags			workouts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Workout ID"
+//	@Success		204	{object}	string
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/workouts/{id} [delete]
+func (app *Application) deleteWorkoutHandler(c *fiber.Ctx) error {
+	workout := getWorkoutFromContext(c)
+
+	if err := app.store.Workouts.Delete(c.Context(), workout.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.store.Activities.DeleteByReference(c.Context(), workout.ID); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	if err := app.jsonResponse(c, http.StatusNoContent, nil); err != nil {
+		return app.internalServerError(c, err)
+	}
+
+	return nil
+}
+
+const workoutCtxKey resourceKey = "workout"
+
+func (app *Application) workoutsContextMiddleware() fiber.Handler {
+	return loadResource(app, workoutCtxKey, app.store.Workouts.GetByID, func(workout *store.Workout) uuid.UUID {
+		return workout.UserID
+	})
+}
+
+func getWorkoutFromContext(c *fiber.Ctx) *store.Workout {
+	return getResourceFromContext[store.Workout](c, workoutCtxKey)
+}
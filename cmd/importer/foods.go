@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+var (
+	errMissingID     = errors.New("missing id")
+	errMissingName   = errors.New("missing name")
+	errNameTooLong   = errors.New("name too long")
+	errBrandTooLong  = errors.New("brand too long")
+	errMissingEnergy = errors.New("missing energy")
+	errOutOfRange    = errors.New("nutrient out of range")
+)
+
+// Both datasets report nutrition per 100 g, or 100 ml for drinks, which is
+// what imported foods are stored per.
+const importServingSize = 100
+
+// kJPerKcal converts energy given only in kilojoules.
+const kJPerKcal = 4.184
+
+// nutrients holds the values of a food per 100 g or ml.
+type nutrients struct {
+	calories  float64
+	hasEnergy bool
+	protein   float64
+	carbs     float64
+	fat       float64
+	fiber     float64
+	micro     store.Micronutrients
+}
+
+// newFood checks the values read from a dataset and maps them onto a food.
+// Missing macros are taken as zero, missing energy rejects the row.
+func newFood(name, description, brand, unit string, n nutrients) (store.Food, error) {
+	name = strings.TrimSpace(name)
+	brand = strings.TrimSpace(brand)
+
+	switch {
+	case name == "":
+		return store.Food{}, errMissingName
+	case utf8.RuneCountInString(name) > 255:
+		return store.Food{}, errNameTooLong
+	case utf8.RuneCountInString(brand) > 255:
+		return store.Food{}, errBrandTooLong
+	case !n.hasEnergy:
+		return store.Food{}, errMissingEnergy
+	}
+
+	// nothing has more energy than pure fat, or more than 100 g of a macro in 100 g
+	if !inRange(n.calories, 1000) || !inRange(n.protein, 100) || !inRange(n.carbs, 100) ||
+		!inRange(n.fat, 100) || !inRange(n.fiber, 100) {
+		return store.Food{}, errOutOfRange
+	}
+
+	// the rest are in milli- and micrograms, but still can't outweigh the 100 g
+	m := n.micro
+	if !inRange(m.Sugar, 100) || !inRange(m.SaturatedFat, 100) || !inRange(m.Sodium, 100e3) ||
+		!inRange(m.Cholesterol, 100e3) || !inRange(m.Potassium, 100e3) || !inRange(m.VitaminA, 100e6) ||
+		!inRange(m.VitaminC, 100e3) || !inRange(m.VitaminD, 100e6) {
+		return store.Food{}, errOutOfRange
+	}
+
+	return store.Food{
+		Name:           name,
+		Description:    strings.TrimSpace(description),
+		Brand:          brand,
+		Calories:       int(math.Round(n.calories)),
+		Protein:        n.protein,
+		Carbs:          n.carbs,
+		Fat:            n.fat,
+		Fiber:          n.fiber,
+		Micronutrients: m,
+		ServingSize:    importServingSize,
+		ServingUnit:    unit,
+	}, nil
+}
+
+// barcode returns the code as a barcode when it is a valid EAN-13 or UPC-A,
+// datasets also hold shorter and shop-internal codes that can't be scanned.
+func barcode(code string) *string {
+	if len(code) == 14 && code[0] == '0' {
+		// GTIN-14 with a zero packaging indicator, as in USDA's gtinUpc
+		code = code[1:]
+	}
+
+	normalized, err := store.NormalizeBarcode(code)
+	if err != nil {
+		return nil
+	}
+
+	return &normalized
+}
+
+func inRange(value, max float64) bool {
+	return !math.IsNaN(value) && value >= 0 && value <= max
+}
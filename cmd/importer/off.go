@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// offProduct is the part of an Open Food Facts product the importer reads. The
+// JSONL dump nests nutriments, the CSV dump flattens them into columns of the
+// same names.
+type offProduct struct {
+	Code            string               `json:"code"`
+	ProductName     string               `json:"product_name"`
+	GenericName     string               `json:"generic_name"`
+	Brands          string               `json:"brands"`
+	ServingQuantity offNumber            `json:"serving_quantity"`
+	Nutriments      map[string]offNumber `json:"nutriments"`
+}
+
+// offNumber reads a number that Open Food Facts stores either as a JSON number
+// or as a string, ok is false when it's missing or empty.
+type offNumber struct {
+	value float64
+	ok    bool
+}
+
+func (n *offNumber) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		// free-text values like "<0.5" are treated as missing
+		return nil
+	}
+
+	*n = offNumber{value: value, ok: true}
+	return nil
+}
+
+func parseOFFNumber(s string) offNumber {
+	var n offNumber
+	n.UnmarshalJSON([]byte(s))
+	return n
+}
+
+// nutrient returns the value of the nutriment per 100 g, working it out from
+// the per serving value when only that was entered.
+func (p offProduct) nutrient(name string) (float64, bool) {
+	if n := p.Nutriments[name+"_100g"]; n.ok {
+		return n.value, true
+	}
+
+	if n := p.Nutriments[name+"_serving"]; n.ok && p.ServingQuantity.ok && p.ServingQuantity.value > 0 {
+		return n.value / p.ServingQuantity.value * importServingSize, true
+	}
+
+	return 0, false
+}
+
+// nutrientIn returns the nutrient converted from grams by the given factor,
+// zero when it's missing.
+func (p offProduct) nutrientIn(name string, factor float64) float64 {
+	value, _ := p.nutrient(name)
+	return value * factor
+}
+
+func (p offProduct) record() record {
+	rec := record{externalID: strings.TrimSpace(p.Code)}
+	if rec.externalID == "" {
+		rec.err = errMissingID
+		return rec
+	}
+
+	var n nutrients
+	n.calories, n.hasEnergy = p.nutrient("energy-kcal")
+	if !n.hasEnergy {
+		var kJ float64
+		kJ, n.hasEnergy = p.nutrient("energy")
+		n.calories = kJ / kJPerKcal
+	}
+	n.protein, _ = p.nutrient("proteins")
+	n.carbs, _ = p.nutrient("carbohydrates")
+	n.fat, _ = p.nutrient("fat")
+	n.fiber, _ = p.nutrient("fiber")
+
+	// Open Food Facts keeps everything in grams
+	n.micro.Sugar, _ = p.nutrient("sugars")
+	n.micro.SaturatedFat, _ = p.nutrient("saturated-fat")
+	n.micro.Sodium = p.nutrientIn("sodium", 1e3)
+	n.micro.Cholesterol = p.nutrientIn("cholesterol", 1e3)
+	n.micro.Potassium = p.nutrientIn("potassium", 1e3)
+	n.micro.VitaminA = p.nutrientIn("vitamin-a", 1e6)
+	n.micro.VitaminC = p.nutrientIn("vitamin-c", 1e3)
+	n.micro.VitaminD = p.nutrientIn("vitamin-d", 1e6)
+
+	// brands lists the brand first, followed by its owners
+	brand, _, _ := strings.Cut(p.Brands, ",")
+
+	rec.food, rec.err = newFood(p.ProductName, p.GenericName, brand, "g", n)
+	rec.food.Barcode = barcode(rec.externalID)
+	return rec
+}
+
+// readOFFJSONL reads the JSONL dump, one product per line.
+func readOFFJSONL(r io.Reader, fn func(record) error) error {
+	scanner := bufio.NewScanner(r)
+	// some products carry enough images and translations to exceed the default
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var product offProduct
+		rec := record{}
+		if err := json.Unmarshal(line, &product); err != nil {
+			rec.err = errors.New("malformed json")
+		} else {
+			rec = product.record()
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readOFFCSV reads the CSV dump, which despite its name is usually tab
+// separated. The separator is taken from the header line.
+func readOFFCSV(r io.Reader, fn func(record) error) error {
+	br := bufio.NewReaderSize(r, 1024*1024)
+
+	header, err := br.Peek(br.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = ','
+	if bytes.Count(header, []byte{'\t'}) > bytes.Count(header, []byte{','}) {
+		reader.Comma = '\t'
+	}
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	columns, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+
+	field := func(row []string, column string) string {
+		if i, ok := index[column]; ok && i < len(row) {
+			return row[i]
+		}
+
+		return ""
+	}
+
+	nutriments := []string{
+		"energy-kcal", "energy", "proteins", "carbohydrates", "fat", "fiber", "sugars", "saturated-fat",
+		"sodium", "cholesterol", "potassium", "vitamin-a", "vitamin-c", "vitamin-d",
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var rec record
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rec.err = errors.New("malformed csv row")
+		case err != nil:
+			return err
+		default:
+			product := offProduct{
+				Code:            field(row, "code"),
+				ProductName:     field(row, "product_name"),
+				GenericName:     field(row, "generic_name"),
+				Brands:          field(row, "brands"),
+				ServingQuantity: parseOFFNumber(field(row, "serving_quantity")),
+				Nutriments:      map[string]offNumber{},
+			}
+			for _, name := range nutriments {
+				for _, suffix := range []string{"_100g", "_serving"} {
+					product.Nutriments[name+suffix] = parseOFFNumber(field(row, name+suffix))
+				}
+			}
+
+			rec = product.record()
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
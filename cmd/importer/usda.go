@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FoodData Central nutrient numbers.
+const (
+	usdaEnergy        = "208"
+	usdaEnergyAtwater = "958"
+	usdaEnergyGeneral = "957"
+	usdaEnergyKJ      = "268"
+	usdaProtein       = "203"
+	usdaCarbs         = "205"
+	usdaFat           = "204"
+	usdaFiber         = "291"
+	usdaSugar         = "269"
+	usdaSugarNLEA     = "269.3"
+	usdaSaturatedFat  = "606"
+	usdaSodium        = "307"
+	usdaCholesterol   = "601"
+	usdaPotassium     = "306"
+	usdaVitaminA      = "320"
+	usdaVitaminC      = "401"
+	usdaVitaminD      = "328"
+)
+
+// usdaFood is the part of a FoodData Central food the importer reads. Every
+// data type reports foodNutrients per 100 g, or 100 ml for branded drinks.
+type usdaFood struct {
+	FdcID               int    `json:"fdcId"`
+	Description         string `json:"description"`
+	BrandOwner          string `json:"brandOwner"`
+	BrandName           string `json:"brandName"`
+	BrandedFoodCategory string `json:"brandedFoodCategory"`
+	ServingSizeUnit     string `json:"servingSizeUnit"`
+	GtinUpc             string `json:"gtinUpc"`
+	FoodNutrients       []struct {
+		Amount   *float64 `json:"amount"`
+		Nutrient struct {
+			Number   string `json:"number"`
+			UnitName string `json:"unitName"`
+		} `json:"nutrient"`
+	} `json:"foodNutrients"`
+}
+
+func (f usdaFood) record() record {
+	rec := record{externalID: strconv.Itoa(f.FdcID)}
+	if f.FdcID == 0 {
+		rec.err = errMissingID
+		return rec
+	}
+
+	amounts := map[string]float64{}
+	for _, fn := range f.FoodNutrients {
+		if fn.Amount != nil {
+			amounts[fn.Nutrient.Number] = *fn.Amount
+		}
+	}
+
+	var n nutrients
+	for _, number := range []string{usdaEnergy, usdaEnergyAtwater, usdaEnergyGeneral} {
+		if kcal, ok := amounts[number]; ok {
+			n.calories, n.hasEnergy = kcal, true
+			break
+		}
+	}
+	if kJ, ok := amounts[usdaEnergyKJ]; ok && !n.hasEnergy {
+		n.calories, n.hasEnergy = kJ/kJPerKcal, true
+	}
+	n.protein = amounts[usdaProtein]
+	n.carbs = amounts[usdaCarbs]
+	n.fat = amounts[usdaFat]
+	n.fiber = amounts[usdaFiber]
+
+	// already in the units foods store them in: g, mg, and µg for vitamins A and D
+	sugar, ok := amounts[usdaSugar]
+	if !ok {
+		sugar = amounts[usdaSugarNLEA]
+	}
+	n.micro.Sugar = sugar
+	n.micro.SaturatedFat = amounts[usdaSaturatedFat]
+	n.micro.Sodium = amounts[usdaSodium]
+	n.micro.Cholesterol = amounts[usdaCholesterol]
+	n.micro.Potassium = amounts[usdaPotassium]
+	n.micro.VitaminA = amounts[usdaVitaminA]
+	n.micro.VitaminC = amounts[usdaVitaminC]
+	n.micro.VitaminD = amounts[usdaVitaminD]
+
+	brand := f.BrandName
+	if brand == "" {
+		brand = f.BrandOwner
+	}
+
+	// branded drinks give their serving in ml, or MLT in older downloads
+	unit := "g"
+	if strings.EqualFold(f.ServingSizeUnit, "ml") || strings.EqualFold(f.ServingSizeUnit, "mlt") {
+		unit = "ml"
+	}
+
+	rec.food, rec.err = newFood(f.Description, f.BrandedFoodCategory, brand, unit, n)
+	rec.food.Barcode = barcode(f.GtinUpc)
+	return rec
+}
+
+// readUSDA streams a FoodData Central JSON download, an object holding the
+// foods under the dataset's name, e.g. {"FoundationFoods": [...]}.
+func readUSDA(r io.Reader, fn func(record) error) error {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, 1024*1024))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		// the dataset name
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+
+		for dec.More() {
+			var food usdaFood
+			if err := dec.Decode(&food); err != nil {
+				return err
+			}
+
+			if err := fn(food.record()); err != nil {
+				return err
+			}
+		}
+
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected %v in usda file, expected %v", tok, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_foods_name_trgm;
+
+ALTER TABLE foods DROP COLUMN IF EXISTS description;
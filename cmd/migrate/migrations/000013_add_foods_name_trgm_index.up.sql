@@ -0,0 +1,7 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram index so typo-tolerant (similarity) food searches don't scan the table
+CREATE INDEX IF NOT EXISTS idx_foods_name_trgm ON foods USING GIN (name gin_trgm_ops);
+
+-- the store has always read and written a description, the column was missing
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
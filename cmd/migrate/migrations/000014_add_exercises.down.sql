@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS exercises;
+
+DROP TYPE IF EXISTS movement_type;
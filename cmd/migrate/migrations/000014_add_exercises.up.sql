@@ -0,0 +1,37 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+DO $$ BEGIN
+    CREATE TYPE movement_type AS ENUM ('compound', 'isolation', 'cardio', 'mobility');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS exercises (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  name VARCHAR(255) NOT NULL UNIQUE,
+  description TEXT NOT NULL DEFAULT '',
+  muscle_groups TEXT[] NOT NULL DEFAULT '{}',
+  equipment VARCHAR(100) NOT NULL DEFAULT 'none',
+  movement_type movement_type NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_exercises_muscle_groups ON exercises USING GIN (muscle_groups);
+
+INSERT INTO
+    exercises (name, muscle_groups, equipment, movement_type)
+VALUES
+    ('Barbell Back Squat', '{quadriceps,glutes,hamstrings}', 'barbell', 'compound'),
+    ('Barbell Bench Press', '{chest,triceps,shoulders}', 'barbell', 'compound'),
+    ('Barbell Deadlift', '{hamstrings,glutes,back}', 'barbell', 'compound'),
+    ('Overhead Press', '{shoulders,triceps}', 'barbell', 'compound'),
+    ('Barbell Row', '{back,biceps}', 'barbell', 'compound'),
+    ('Pull Up', '{back,biceps}', 'bodyweight', 'compound'),
+    ('Dumbbell Bicep Curl', '{biceps}', 'dumbbell', 'isolation'),
+    ('Cable Tricep Pushdown', '{triceps}', 'cable', 'isolation'),
+    ('Leg Extension', '{quadriceps}', 'machine', 'isolation'),
+    ('Running', '{quadriceps,hamstrings,calves}', 'none', 'cardio'),
+    ('Rowing Machine', '{back,quadriceps}', 'machine', 'cardio')
+ON CONFLICT (name) DO NOTHING;
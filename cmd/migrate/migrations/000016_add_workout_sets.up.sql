@@ -0,0 +1,18 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS workout_sets (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  workout_id UUID NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  exercise_id UUID NOT NULL REFERENCES exercises(id),
+  position INTEGER NOT NULL CHECK (position >= 0),
+  reps INTEGER CHECK (reps >= 0),
+  weight DECIMAL(8,2) CHECK (weight >= 0),
+  rpe DECIMAL(3,1) CHECK (rpe BETWEEN 1 AND 10),
+  duration_seconds INTEGER CHECK (duration_seconds >= 0),
+  distance_meters DECIMAL(10,2) CHECK (distance_meters >= 0),
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (workout_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_sets_exercise_id ON workout_sets (exercise_id);
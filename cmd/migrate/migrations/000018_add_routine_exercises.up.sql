@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS routine_exercises (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  routine_id UUID NOT NULL REFERENCES routines(id) ON DELETE CASCADE,
+  exercise_id UUID NOT NULL REFERENCES exercises(id),
+  position INTEGER NOT NULL CHECK (position >= 0),
+  target_sets INTEGER NOT NULL CHECK (target_sets > 0),
+  target_reps INTEGER NOT NULL CHECK (target_reps > 0),
+  target_weight DECIMAL(8,2) CHECK (target_weight >= 0),
+  UNIQUE (routine_id, position)
+);
@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS programs (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  weeks INTEGER NOT NULL CHECK (weeks > 0),
+  weight_increment DECIMAL(8,2) NOT NULL DEFAULT 0 CHECK (weight_increment >= 0),
+  deload_every INTEGER NOT NULL DEFAULT 0 CHECK (deload_every >= 0),
+  deload_percent DECIMAL(5,2) NOT NULL DEFAULT 100 CHECK (deload_percent > 0 AND deload_percent <= 100),
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS program_days (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  program_id UUID NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+  day_number INTEGER NOT NULL CHECK (day_number > 0),
+  routine_id UUID NOT NULL REFERENCES routines(id),
+  UNIQUE (program_id, day_number)
+);
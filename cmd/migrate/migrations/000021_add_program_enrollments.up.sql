@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  program_id UUID NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+  current_week INTEGER NOT NULL DEFAULT 1 CHECK (current_week > 0),
+  current_day INTEGER NOT NULL DEFAULT 1 CHECK (current_day > 0),
+  started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  completed_at TIMESTAMP WITH TIME ZONE,
+  UNIQUE (user_id, program_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE
+  users DROP COLUMN share_workouts,
+  DROP COLUMN share_records;
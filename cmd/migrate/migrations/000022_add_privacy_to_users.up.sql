@@ -0,0 +1,6 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN share_workouts BOOLEAN NOT NULL DEFAULT TRUE,
+ADD
+  COLUMN share_records BOOLEAN NOT NULL DEFAULT TRUE;
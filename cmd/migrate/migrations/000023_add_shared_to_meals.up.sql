@@ -0,0 +1,4 @@
+ALTER TABLE
+  meals
+ADD
+  COLUMN shared BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS activities;
+
+DROP TYPE IF EXISTS activity_type;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS nutrition_profiles;
+
+DROP TYPE IF EXISTS bmr_formula;
+DROP TYPE IF EXISTS weight_goal;
+DROP TYPE IF EXISTS activity_level;
+DROP TYPE IF EXISTS sex;
@@ -0,0 +1,38 @@
+DO $$ BEGIN
+    CREATE TYPE sex AS ENUM ('male', 'female');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+DO $$ BEGIN
+    CREATE TYPE activity_level AS ENUM ('sedentary', 'light', 'moderate', 'active', 'very_active');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+DO $$ BEGIN
+    CREATE TYPE weight_goal AS ENUM ('lose', 'maintain', 'gain');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+DO $$ BEGIN
+    CREATE TYPE bmr_formula AS ENUM ('mifflin_st_jeor', 'katch_mcardle');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+CREATE TABLE IF NOT EXISTS nutrition_profiles (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  sex sex NOT NULL,
+  birth_date DATE NOT NULL,
+  height_cm DECIMAL(5,1) NOT NULL CHECK (height_cm > 0),
+  weight_kg DECIMAL(5,1) NOT NULL CHECK (weight_kg > 0),
+  body_fat_percent DECIMAL(4,1) CHECK (body_fat_percent > 0 AND body_fat_percent < 100),
+  activity_level activity_level NOT NULL,
+  goal weight_goal NOT NULL DEFAULT 'maintain',
+  goal_rate_kg DECIMAL(3,2) NOT NULL DEFAULT 0 CHECK (goal_rate_kg >= 0),
+  bmr_formula bmr_formula NOT NULL DEFAULT 'mifflin_st_jeor',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- Targets are never updated in place, a new row takes effect from its date so
+-- past diary days keep being compared against the targets of the time.
+CREATE TABLE IF NOT EXISTS nutrition_targets (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  effective_from DATE NOT NULL,
+  calories DECIMAL(7,1) NOT NULL CHECK (calories > 0),
+  protein DECIMAL(6,1) NOT NULL CHECK (protein >= 0),
+  carbs DECIMAL(6,1) NOT NULL CHECK (carbs >= 0),
+  fat DECIMAL(6,1) NOT NULL CHECK (fat >= 0),
+  fiber DECIMAL(6,1) NOT NULL CHECK (fiber >= 0),
+  protein_percent DECIMAL(4,1),
+  carbs_percent DECIMAL(4,1),
+  fat_percent DECIMAL(4,1),
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_nutrition_targets_user_id_effective_from ON nutrition_targets (user_id, effective_from DESC, created_at DESC);
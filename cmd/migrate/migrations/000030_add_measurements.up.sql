@@ -0,0 +1,19 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- value/unit are normalised to kg, cm or % so that a type can be charted no
+-- matter which unit each entry was logged in, the entered_* columns keep what
+-- the user typed.
+CREATE TABLE IF NOT EXISTS measurements (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(50) NOT NULL,
+  value DECIMAL(8,2) NOT NULL CHECK (value > 0),
+  unit VARCHAR(10) NOT NULL,
+  entered_value DECIMAL(8,2) NOT NULL,
+  entered_unit VARCHAR(10) NOT NULL,
+  notes TEXT NOT NULL DEFAULT '',
+  measured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurements_user_id_type_measured_at ON measurements (user_id, type, measured_at DESC);
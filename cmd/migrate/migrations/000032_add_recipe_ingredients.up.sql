@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- amount is in the food's serving unit, like the amount of a meal entry
+CREATE TABLE IF NOT EXISTS recipe_ingredients (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  recipe_id UUID NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+  food_id UUID NOT NULL REFERENCES foods(id),
+  position INTEGER NOT NULL CHECK (position >= 0),
+  amount DECIMAL(8,2) NOT NULL CHECK (amount > 0),
+  UNIQUE (recipe_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_ingredients_food_id ON recipe_ingredients (food_id);
@@ -0,0 +1,5 @@
+DELETE FROM meal_entries WHERE recipe_id IS NOT NULL;
+
+ALTER TABLE meal_entries DROP CONSTRAINT IF EXISTS meal_entries_food_or_recipe;
+ALTER TABLE meal_entries DROP COLUMN IF EXISTS recipe_id;
+ALTER TABLE meal_entries ALTER COLUMN food_id SET NOT NULL;
@@ -0,0 +1,6 @@
+-- an entry logs either a food, in the food's serving unit, or servings of a recipe
+ALTER TABLE meal_entries ALTER COLUMN food_id DROP NOT NULL;
+ALTER TABLE meal_entries ADD COLUMN IF NOT EXISTS recipe_id UUID REFERENCES recipes(id);
+ALTER TABLE meal_entries ADD CONSTRAINT meal_entries_food_or_recipe CHECK ((food_id IS NULL) <> (recipe_id IS NULL));
+
+CREATE INDEX IF NOT EXISTS idx_meal_entries_recipe_id ON meal_entries (recipe_id);
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- like meal entries, a template entry is either a food or servings of a recipe.
+-- Deleting a recipe just drops it from templates, nothing has been eaten yet.
+CREATE TABLE IF NOT EXISTS meal_template_entries (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  template_id UUID NOT NULL REFERENCES meal_templates(id) ON DELETE CASCADE,
+  food_id UUID REFERENCES foods(id),
+  recipe_id UUID REFERENCES recipes(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL CHECK (position >= 0),
+  serving_unit VARCHAR(50) NOT NULL,
+  amount DECIMAL(8,2) NOT NULL CHECK (amount > 0),
+  UNIQUE (template_id, position),
+  CHECK ((food_id IS NULL) <> (recipe_id IS NULL))
+);
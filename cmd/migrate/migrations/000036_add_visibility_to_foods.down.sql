@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_foods_user_id_created_at;
+
+ALTER TABLE foods DROP COLUMN IF EXISTS visibility;
+
+DROP TYPE IF EXISTS food_visibility;
@@ -0,0 +1,10 @@
+DO $$ BEGIN
+    CREATE TYPE food_visibility AS ENUM ('private', 'public');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- foods added before creators were recorded have no user_id and stay public
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS visibility food_visibility NOT NULL DEFAULT 'public';
+
+CREATE INDEX IF NOT EXISTS idx_foods_user_id_created_at ON foods (user_id, created_at DESC);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS favourite_foods (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, food_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_favourite_foods_user_id_created_at ON favourite_foods (user_id, created_at DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_foods_status_created_at;
+
+ALTER TABLE foods DROP COLUMN IF EXISTS rejection_reason;
+ALTER TABLE foods DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS food_status;
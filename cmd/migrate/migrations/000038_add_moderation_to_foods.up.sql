@@ -0,0 +1,13 @@
+DO $$ BEGIN
+    CREATE TYPE food_status AS ENUM ('pending', 'approved', 'rejected');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- foods already in the catalog are taken as approved, new submissions wait
+-- for a moderator
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS status food_status NOT NULL DEFAULT 'approved';
+ALTER TABLE foods ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS rejection_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_foods_status_created_at ON foods (status, created_at) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS food_moderation_actions;
+
+DROP TYPE IF EXISTS moderation_action;
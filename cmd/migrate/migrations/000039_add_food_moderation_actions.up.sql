@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+DO $$ BEGIN
+    CREATE TYPE moderation_action AS ENUM ('approve', 'reject', 'edit');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- moderator_id is kept nullable so the log outlives deleted moderator accounts
+CREATE TABLE IF NOT EXISTS food_moderation_actions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  action moderation_action NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  changes JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_food_moderation_actions_food_id ON food_moderation_actions (food_id, created_at DESC);
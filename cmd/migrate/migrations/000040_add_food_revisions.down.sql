@@ -0,0 +1 @@
+DROP TABLE IF EXISTS food_revisions;
@@ -0,0 +1,25 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- every edit to a food stores the version it replaced, valid from valid_from
+-- until replaced_at, so entries logged in between keep their original values
+CREATE TABLE IF NOT EXISTS food_revisions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  revision INTEGER NOT NULL CHECK (revision > 0),
+  name VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  brand VARCHAR(255) NOT NULL DEFAULT '',
+  calories INTEGER NOT NULL CHECK (calories >= 0),
+  protein DECIMAL(8,2) NOT NULL CHECK (protein >= 0),
+  carbs DECIMAL(8,2) NOT NULL CHECK (carbs >= 0),
+  fat DECIMAL(8,2) NOT NULL CHECK (fat >= 0),
+  fiber DECIMAL(8,2) NOT NULL CHECK (fiber >= 0),
+  serving_size DECIMAL(8,2) NOT NULL CHECK (serving_size > 0),
+  serving_unit VARCHAR(50) NOT NULL,
+  edited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+  replaced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (food_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_food_revisions_food_id_replaced_at ON food_revisions (food_id, replaced_at);
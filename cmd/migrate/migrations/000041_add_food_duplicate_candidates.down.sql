@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS food_duplicate_candidates;
+
+DROP INDEX IF EXISTS idx_foods_label_trgm;
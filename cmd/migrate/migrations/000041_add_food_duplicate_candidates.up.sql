@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram index on the label duplicates are matched on, name and brand together
+CREATE INDEX IF NOT EXISTS idx_foods_label_trgm ON foods USING GIN ((lower(name || ' ' || COALESCE(brand, ''))) gin_trgm_ops);
+
+-- pairs found by the duplicate detection job, food_id is always the lower id so
+-- every pair is stored once
+CREATE TABLE IF NOT EXISTS food_duplicate_candidates (
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  duplicate_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  similarity REAL NOT NULL,
+  detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (food_id, duplicate_id),
+  CHECK (food_id < duplicate_id)
+);
@@ -0,0 +1,10 @@
+-- the ids of foods merged into another one keep resolving to the food they
+-- were merged into
+CREATE TABLE IF NOT EXISTS food_redirects (
+  from_id UUID PRIMARY KEY,
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  merged_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_food_redirects_food_id ON food_redirects (food_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_foods_source_external_id;
+
+ALTER TABLE foods DROP COLUMN IF EXISTS external_id;
+ALTER TABLE foods DROP COLUMN IF EXISTS source;
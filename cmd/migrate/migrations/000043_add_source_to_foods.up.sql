@@ -0,0 +1,6 @@
+-- foods imported from external datasets remember where they came from so
+-- re-running an import updates them instead of adding copies
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS source VARCHAR(50);
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_foods_source_external_id ON foods (source, external_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_foods_barcode_user_id;
+DROP INDEX IF EXISTS idx_foods_barcode;
+
+ALTER TABLE foods DROP COLUMN IF EXISTS barcode;
@@ -0,0 +1,7 @@
+-- barcodes are stored as EAN-13, UPC-A codes get a leading zero. Only one
+-- approved public food may have a barcode, foods waiting for moderation or
+-- rejected ones don't hold it, and users keep their own private versions.
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS barcode VARCHAR(13) CHECK (barcode ~ '^[0-9]{13}$');
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_foods_barcode ON foods (barcode) WHERE visibility = 'public' AND status = 'approved';
+CREATE INDEX IF NOT EXISTS idx_foods_barcode_user_id ON foods (barcode, user_id) WHERE visibility = 'private';
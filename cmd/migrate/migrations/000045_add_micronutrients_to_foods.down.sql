@@ -0,0 +1,19 @@
+ALTER TABLE food_revisions
+  DROP COLUMN IF EXISTS sugar,
+  DROP COLUMN IF EXISTS saturated_fat,
+  DROP COLUMN IF EXISTS sodium,
+  DROP COLUMN IF EXISTS cholesterol,
+  DROP COLUMN IF EXISTS potassium,
+  DROP COLUMN IF EXISTS vitamin_a,
+  DROP COLUMN IF EXISTS vitamin_c,
+  DROP COLUMN IF EXISTS vitamin_d;
+
+ALTER TABLE foods
+  DROP COLUMN IF EXISTS sugar,
+  DROP COLUMN IF EXISTS saturated_fat,
+  DROP COLUMN IF EXISTS sodium,
+  DROP COLUMN IF EXISTS cholesterol,
+  DROP COLUMN IF EXISTS potassium,
+  DROP COLUMN IF EXISTS vitamin_a,
+  DROP COLUMN IF EXISTS vitamin_c,
+  DROP COLUMN IF EXISTS vitamin_d;
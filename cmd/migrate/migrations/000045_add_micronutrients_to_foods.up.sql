@@ -0,0 +1,22 @@
+-- label detail beyond the macros, per serving like the macros: sugar and
+-- saturated fat in grams, sodium, cholesterol, potassium and vitamin C in
+-- milligrams, vitamins A and D in micrograms. Zero when the label doesn't say.
+ALTER TABLE foods
+  ADD COLUMN IF NOT EXISTS sugar DECIMAL(8,2) NOT NULL DEFAULT 0 CHECK (sugar >= 0),
+  ADD COLUMN IF NOT EXISTS saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0 CHECK (saturated_fat >= 0),
+  ADD COLUMN IF NOT EXISTS sodium DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (sodium >= 0),
+  ADD COLUMN IF NOT EXISTS cholesterol DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (cholesterol >= 0),
+  ADD COLUMN IF NOT EXISTS potassium DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (potassium >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_a DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_a >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_c DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_c >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_d DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_d >= 0);
+
+ALTER TABLE food_revisions
+  ADD COLUMN IF NOT EXISTS sugar DECIMAL(8,2) NOT NULL DEFAULT 0 CHECK (sugar >= 0),
+  ADD COLUMN IF NOT EXISTS saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0 CHECK (saturated_fat >= 0),
+  ADD COLUMN IF NOT EXISTS sodium DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (sodium >= 0),
+  ADD COLUMN IF NOT EXISTS cholesterol DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (cholesterol >= 0),
+  ADD COLUMN IF NOT EXISTS potassium DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (potassium >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_a DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_a >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_c DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_c >= 0),
+  ADD COLUMN IF NOT EXISTS vitamin_d DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (vitamin_d >= 0);
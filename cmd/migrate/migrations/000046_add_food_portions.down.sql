@@ -0,0 +1,13 @@
+ALTER TABLE recipe_ingredients DROP COLUMN IF EXISTS unit;
+
+-- converted amounts stay converted, they are still right in their unit
+UPDATE meal_template_entries SET serving_unit = legacy_unit WHERE legacy_unit IS NOT NULL;
+ALTER TABLE meal_template_entries DROP COLUMN IF EXISTS legacy_unit;
+
+UPDATE meal_entries SET serving_unit = legacy_unit WHERE legacy_unit IS NOT NULL;
+ALTER TABLE meal_entries DROP COLUMN IF EXISTS legacy_unit;
+
+DROP TABLE IF EXISTS food_portions;
+
+ALTER TABLE food_revisions DROP COLUMN IF EXISTS density;
+ALTER TABLE foods DROP COLUMN IF EXISTS density;
@@ -0,0 +1,121 @@
+-- density in g/ml lets amounts of a food be converted between mass and volume.
+-- Revisions keep it so entries convert the way they did when they were logged.
+ALTER TABLE foods ADD COLUMN IF NOT EXISTS density DECIMAL(8,3) CHECK (density > 0);
+ALTER TABLE food_revisions ADD COLUMN IF NOT EXISTS density DECIMAL(8,3) CHECK (density > 0);
+
+-- household measures of a food, e.g. 1 slice = 28 g. Names are stored
+-- normalised, as meal entries refer to them by their serving unit.
+CREATE TABLE IF NOT EXISTS food_portions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  food_id UUID NOT NULL REFERENCES foods(id) ON DELETE CASCADE,
+  name VARCHAR(50) NOT NULL,
+  amount DECIMAL(8,2) NOT NULL CHECK (amount > 0),
+  unit VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (food_id, name)
+);
+
+-- Amounts saved before units were checked were totalled as if they were in
+-- the food's serving unit at the time. Those written down in a unit that
+-- converts to it are converted. The rest are relabelled with the serving unit,
+-- keeping what they were written as in legacy_unit so they can be fixed.
+
+-- mirrors internal/units, factors convert to grams or millilitres
+CREATE TEMPORARY TABLE legacy_units (
+  name VARCHAR(50) PRIMARY KEY,
+  dimension VARCHAR(10) NOT NULL,
+  factor DOUBLE PRECISION NOT NULL
+);
+
+INSERT INTO legacy_units (name, dimension, factor) VALUES
+  ('g', 'mass', 1), ('gram', 'mass', 1), ('grams', 'mass', 1), ('gr', 'mass', 1),
+  ('kg', 'mass', 1000), ('kilogram', 'mass', 1000), ('kilograms', 'mass', 1000),
+  ('mg', 'mass', 0.001), ('milligram', 'mass', 0.001), ('milligrams', 'mass', 0.001),
+  ('oz', 'mass', 28.349523125), ('ounce', 'mass', 28.349523125), ('ounces', 'mass', 28.349523125),
+  ('lb', 'mass', 453.59237), ('lbs', 'mass', 453.59237), ('pound', 'mass', 453.59237), ('pounds', 'mass', 453.59237),
+  ('ml', 'volume', 1), ('millilitre', 'volume', 1), ('millilitres', 'volume', 1), ('milliliter', 'volume', 1), ('milliliters', 'volume', 1),
+  ('l', 'volume', 1000), ('litre', 'volume', 1000), ('litres', 'volume', 1000), ('liter', 'volume', 1000), ('liters', 'volume', 1000),
+  ('tsp', 'volume', 4.92892159375), ('teaspoon', 'volume', 4.92892159375), ('teaspoons', 'volume', 4.92892159375),
+  ('tbsp', 'volume', 14.78676478125), ('tablespoon', 'volume', 14.78676478125), ('tablespoons', 'volume', 14.78676478125),
+  ('fl oz', 'volume', 29.5735295625), ('floz', 'volume', 29.5735295625), ('fluid ounce', 'volume', 29.5735295625), ('fluid ounces', 'volume', 29.5735295625),
+  ('cup', 'volume', 236.5882365), ('cups', 'volume', 236.5882365),
+  ('pint', 'volume', 473.176473), ('pints', 'volume', 473.176473);
+
+-- units.Normalize, lower cased with whitespace collapsed to single spaces
+CREATE FUNCTION pg_temp.normalize_unit(unit TEXT) RETURNS TEXT AS $$
+  SELECT trim(regexp_replace(lower(unit), '\s+', ' ', 'g'))
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- legacy_amount converts amount in unit to serving_unit. It is null when the
+-- units don't convert, the food has no density yet so mass and volume don't,
+-- or the result doesn't fit an amount column.
+CREATE FUNCTION pg_temp.legacy_amount(amount NUMERIC, unit TEXT, serving_unit TEXT) RETURNS NUMERIC AS $$
+  SELECT converted FROM (
+    SELECT CASE
+      WHEN pg_temp.normalize_unit(unit) IN (pg_temp.normalize_unit(serving_unit), 'serving') THEN amount
+      ELSE (
+        SELECT round((amount * f.factor / t.factor)::numeric, 2)
+        FROM pg_temp.legacy_units f
+        JOIN pg_temp.legacy_units t ON t.dimension = f.dimension
+        WHERE f.name = pg_temp.normalize_unit(unit) AND t.name = pg_temp.normalize_unit(serving_unit)
+      )
+    END AS converted
+  ) c
+  WHERE converted > 0 AND converted < 1000000
+$$ LANGUAGE SQL STABLE;
+
+-- legacy_unit is the unit an amount becomes in, serving_unit unless it was
+-- logged in servings, which still convert
+CREATE FUNCTION pg_temp.legacy_unit(unit TEXT, serving_unit TEXT) RETURNS TEXT AS $$
+  SELECT CASE WHEN pg_temp.normalize_unit(unit) = 'serving' THEN 'serving' ELSE pg_temp.normalize_unit(serving_unit) END
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE meal_entries ADD COLUMN IF NOT EXISTS legacy_unit VARCHAR(50);
+ALTER TABLE meal_template_entries ADD COLUMN IF NOT EXISTS legacy_unit VARCHAR(50);
+
+-- meal entries convert to the serving unit the food had when they were logged
+UPDATE meal_entries e
+SET amount = COALESCE(pg_temp.legacy_amount(e.amount, e.serving_unit, u.serving_unit), e.amount),
+  serving_unit = CASE
+    WHEN pg_temp.legacy_amount(e.amount, e.serving_unit, u.serving_unit) IS NULL THEN pg_temp.normalize_unit(u.serving_unit)
+    ELSE pg_temp.legacy_unit(e.serving_unit, u.serving_unit)
+  END,
+  legacy_unit = CASE WHEN pg_temp.legacy_amount(e.amount, e.serving_unit, u.serving_unit) IS NULL THEN e.serving_unit END
+FROM (
+  SELECT e.id, COALESCE((
+    SELECT fr.serving_unit
+    FROM food_revisions fr
+    WHERE fr.food_id = e.food_id AND fr.replaced_at > e.created_at
+    ORDER BY fr.replaced_at ASC
+    LIMIT 1
+  ), f.serving_unit) AS serving_unit
+  FROM meal_entries e
+  JOIN foods f ON f.id = e.food_id
+) u
+WHERE u.id = e.id;
+
+UPDATE meal_template_entries t
+SET amount = COALESCE(pg_temp.legacy_amount(t.amount, t.serving_unit, f.serving_unit), t.amount),
+  serving_unit = CASE
+    WHEN pg_temp.legacy_amount(t.amount, t.serving_unit, f.serving_unit) IS NULL THEN pg_temp.normalize_unit(f.serving_unit)
+    ELSE pg_temp.legacy_unit(t.serving_unit, f.serving_unit)
+  END,
+  legacy_unit = CASE WHEN pg_temp.legacy_amount(t.amount, t.serving_unit, f.serving_unit) IS NULL THEN t.serving_unit END
+FROM foods f
+WHERE f.id = t.food_id;
+
+-- recipe ingredients are measured in unit like meal entries, those saved
+-- before it was recorded are in the food's serving unit
+ALTER TABLE recipe_ingredients ADD COLUMN IF NOT EXISTS unit VARCHAR(50);
+
+UPDATE recipe_ingredients ri
+SET unit = pg_temp.normalize_unit(f.serving_unit)
+FROM foods f
+WHERE f.id = ri.food_id;
+
+ALTER TABLE recipe_ingredients ALTER COLUMN unit SET NOT NULL;
+
+DROP FUNCTION pg_temp.legacy_unit(TEXT, TEXT);
+DROP FUNCTION pg_temp.legacy_amount(NUMERIC, TEXT, TEXT);
+DROP FUNCTION pg_temp.normalize_unit(TEXT);
+DROP TABLE legacy_units;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS recipe_revisions;
+
+DELETE FROM recipe_ingredients WHERE replaced_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_recipe_ingredients_recipe_id_position;
+ALTER TABLE recipe_ingredients ADD CONSTRAINT recipe_ingredients_recipe_id_position_key UNIQUE (recipe_id, position);
+
+ALTER TABLE recipe_ingredients
+  DROP COLUMN IF EXISTS replaced_at,
+  DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,29 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+-- editing a recipe keeps the ingredients it replaced, valid from created_at
+-- until replaced_at, so entries logged before the edit keep their nutrition
+ALTER TABLE recipe_ingredients
+  ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS replaced_at TIMESTAMP WITH TIME ZONE;
+
+-- earlier edits replaced the ingredients outright, the current ones are all
+-- that is known about the recipe
+UPDATE recipe_ingredients ri
+SET created_at = r.created_at
+FROM recipes r
+WHERE r.id = ri.recipe_id AND r.created_at IS NOT NULL;
+
+ALTER TABLE recipe_ingredients DROP CONSTRAINT IF EXISTS recipe_ingredients_recipe_id_position_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipe_ingredients_recipe_id_position ON recipe_ingredients (recipe_id, position) WHERE replaced_at IS NULL;
+
+-- the name and servings a recipe had before each edit, like food_revisions
+CREATE TABLE IF NOT EXISTS recipe_revisions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  recipe_id UUID NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  servings DECIMAL(6,2) NOT NULL CHECK (servings > 0),
+  valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+  replaced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_revisions_recipe_id_replaced_at ON recipe_revisions (recipe_id, replaced_at);
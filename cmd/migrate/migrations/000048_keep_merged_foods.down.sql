@@ -0,0 +1,2 @@
+-- enum values can't be dropped, merged foods are left rejected instead
+UPDATE foods SET status = 'rejected' WHERE status = 'merged';
@@ -0,0 +1,3 @@
+-- merged foods are kept, with their revisions and import key, for the entries
+-- logged against them, their ids resolve to the food they were merged into
+ALTER TYPE food_status ADD VALUE IF NOT EXISTS 'merged';
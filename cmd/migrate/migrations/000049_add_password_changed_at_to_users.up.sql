@@ -0,0 +1,2 @@
+-- access tokens issued before the password last changed are no longer accepted
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE;
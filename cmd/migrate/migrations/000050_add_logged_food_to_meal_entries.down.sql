@@ -0,0 +1,3 @@
+UPDATE meal_entries SET food_id = logged_food_id WHERE logged_food_id IS NOT NULL;
+
+ALTER TABLE meal_entries DROP COLUMN IF EXISTS logged_food_id;
@@ -0,0 +1,8 @@
+-- entries of a merged food point at the food it was merged into, logged_food_id
+-- keeps the food their values were logged against
+ALTER TABLE meal_entries ADD COLUMN IF NOT EXISTS logged_food_id UUID REFERENCES foods(id);
+
+UPDATE meal_entries e
+SET logged_food_id = e.food_id, food_id = rd.food_id
+FROM food_redirects rd
+WHERE rd.from_id = e.food_id;
@@ -31,7 +31,7 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 	to := mail.NewEmail(username, email)
 
 	// Template parsing
-	template, err := template.ParseFS(FS, "templates/"+UserWelcomeTemplate)
+	template, err := template.ParseFS(FS, "templates/"+templateFile)
 	if err != nil {
 		return -1, err
 	}
@@ -1,47 +1,61 @@
 package ratelimiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
 type FixedWindowRateLimiter struct {
-	sync.RWMutex
-	clients map[string]int
+	sync.Mutex
+	clients map[string]*fixedWindow
 	limit   int
 	window  time.Duration
+	sweptAt time.Time
+}
+
+type fixedWindow struct {
+	count   int
+	resetAt time.Time
 }
 
 func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowRateLimiter {
 	return &FixedWindowRateLimiter{
-		clients: make(map[string]int),
+		clients: make(map[string]*fixedWindow),
 		limit:   limit,
 		window:  window,
+		sweptAt: time.Now(),
 	}
 }
 
-func (rl *FixedWindowRateLimiter) Allow(ip string) (bool, time.Duration) {
-	rl.RLock()
-	count, exists := rl.clients[ip]
-	rl.RUnlock()
+func (rl *FixedWindowRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	rl.Lock()
+	defer rl.Unlock()
 
-	if !exists || count < rl.limit {
-		rl.Lock()
-		if !exists {
-			go rl.resetCount(ip)
-		}
+	now := time.Now()
+	rl.sweptAt = sweep(rl.clients, rl.sweptAt, now, rl.window, func(w *fixedWindow) bool {
+		return !now.Before(w.resetAt)
+	})
 
-		rl.clients[ip]++
-		rl.Unlock()
-		return true, 0
+	client, exists := rl.clients[key]
+	if !exists || !now.Before(client.resetAt) {
+		client = &fixedWindow{resetAt: now.Add(rl.window)}
+		rl.clients[key] = client
 	}
 
-	return false, rl.window
-}
+	res := Result{
+		Limit: rl.limit,
+		Reset: time.Until(client.resetAt),
+	}
 
-func (rl *FixedWindowRateLimiter) resetCount(ip string) {
-	time.Sleep(rl.window)
-	rl.Lock()
-	delete(rl.clients, ip)
-	rl.Unlock()
+	if client.count >= rl.limit {
+		res.RetryAfter = res.Reset
+		return res, nil
+	}
+
+	client.count++
+
+	res.Allowed = true
+	res.Remaining = rl.limit - client.count
+	return res, nil
 }
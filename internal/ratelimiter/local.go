@@ -0,0 +1,226 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// The local limiters keep their counts in process for when there is no redis,
+// so every replica enforces the limit on its own. Idle clients are swept out at
+// most once per window instead of each key holding a timer.
+
+type LocalTokenBucketRateLimiter struct {
+	sync.Mutex
+	clients  map[string]*localBucket
+	capacity int
+	window   time.Duration
+	// refillRate is in tokens per millisecond
+	refillRate float64
+	sweptAt    time.Time
+}
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewLocalTokenBucketLimiter mirrors NewTokenBucketLimiter without redis.
+func NewLocalTokenBucketLimiter(limit int, window time.Duration) *LocalTokenBucketRateLimiter {
+	return &LocalTokenBucketRateLimiter{
+		clients:    make(map[string]*localBucket),
+		capacity:   limit,
+		window:     window,
+		refillRate: float64(limit) / float64(window.Milliseconds()),
+		sweptAt:    time.Now(),
+	}
+}
+
+func (rl *LocalTokenBucketRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	// a bucket left alone for a window has refilled, it is the same as a new one
+	rl.sweptAt = sweep(rl.clients, rl.sweptAt, now, rl.window, func(b *localBucket) bool {
+		return now.Sub(b.ts) >= rl.window
+	})
+
+	b, exists := rl.clients[key]
+	if !exists {
+		b = &localBucket{tokens: float64(rl.capacity), ts: now}
+		rl.clients[key] = b
+	}
+
+	elapsed := float64(now.Sub(b.ts).Milliseconds())
+	b.tokens = math.Min(float64(rl.capacity), b.tokens+math.Max(0, elapsed)*rl.refillRate)
+	b.ts = now
+
+	res := Result{Limit: rl.capacity}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = millis(math.Ceil((1 - b.tokens) / rl.refillRate))
+	}
+
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = millis(math.Ceil((float64(rl.capacity) - b.tokens) / rl.refillRate))
+
+	return res, nil
+}
+
+type LocalSlidingWindowRateLimiter struct {
+	sync.Mutex
+	clients map[string]*localWindow
+	limit   int
+	window  time.Duration
+	sweptAt time.Time
+}
+
+type localWindow struct {
+	current int64
+	curr    int
+	prev    int
+}
+
+// NewLocalSlidingWindowLimiter mirrors NewSlidingWindowLimiter without redis.
+func NewLocalSlidingWindowLimiter(limit int, window time.Duration) *LocalSlidingWindowRateLimiter {
+	return &LocalSlidingWindowRateLimiter{
+		clients: make(map[string]*localWindow),
+		limit:   limit,
+		window:  window,
+		sweptAt: time.Now(),
+	}
+}
+
+func (rl *LocalSlidingWindowRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	window := rl.window.Milliseconds()
+	current := now.UnixMilli() / window
+	elapsed := now.UnixMilli() - current*window
+
+	// once both of its windows have passed a client has nothing left to count
+	rl.sweptAt = sweep(rl.clients, rl.sweptAt, now, rl.window, func(w *localWindow) bool {
+		return w.current < current-1
+	})
+
+	w, exists := rl.clients[key]
+	if !exists {
+		w = &localWindow{current: current}
+		rl.clients[key] = w
+	}
+
+	switch w.current {
+	case current:
+	case current - 1:
+		w.prev, w.curr = w.curr, 0
+	default:
+		w.prev, w.curr = 0, 0
+	}
+	w.current = current
+
+	count := float64(w.prev)*float64(window-elapsed)/float64(window) + float64(w.curr)
+
+	res := Result{Limit: rl.limit}
+	if count+1 <= float64(rl.limit) {
+		w.curr++
+		count++
+		res.Allowed = true
+	}
+
+	switch {
+	case w.curr > 0:
+		res.Reset = millis(float64(window - elapsed + window))
+	case w.prev > 0:
+		res.Reset = millis(float64(window - elapsed))
+	}
+
+	if !res.Allowed {
+		if w.prev > 0 && w.curr+1 <= rl.limit {
+			// wait for enough of the previous window to slide out
+			res.RetryAfter = millis(math.Ceil(float64(window-elapsed) - float64(rl.limit-1-w.curr)*float64(window)/float64(w.prev)))
+		} else {
+			res.RetryAfter = millis(float64(window - elapsed))
+		}
+	}
+
+	res.Remaining = int(math.Max(0, math.Floor(float64(rl.limit)-count)))
+
+	return res, nil
+}
+
+type LocalSlidingLogRateLimiter struct {
+	sync.Mutex
+	clients map[string][]time.Time
+	limit   int
+	window  time.Duration
+	sweptAt time.Time
+}
+
+// NewLocalSlidingLogLimiter mirrors NewSlidingLogLimiter without redis.
+func NewLocalSlidingLogLimiter(limit int, window time.Duration) *LocalSlidingLogRateLimiter {
+	return &LocalSlidingLogRateLimiter{
+		clients: make(map[string][]time.Time),
+		limit:   limit,
+		window:  window,
+		sweptAt: time.Now(),
+	}
+}
+
+func (rl *LocalSlidingLogRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	rl.sweptAt = sweep(rl.clients, rl.sweptAt, now, rl.window, func(log []time.Time) bool {
+		return len(log) == 0 || now.Sub(log[len(log)-1]) >= rl.window
+	})
+
+	// drop the requests that have left the window, the log is kept in order
+	log := rl.clients[key]
+	for len(log) > 0 && now.Sub(log[0]) >= rl.window {
+		log = log[1:]
+	}
+
+	res := Result{Limit: rl.limit}
+	if len(log) < rl.limit {
+		log = append(log, now)
+		res.Allowed = true
+	}
+	rl.clients[key] = log
+
+	res.Remaining = rl.limit - len(log)
+	if len(log) > 0 {
+		res.Reset = log[len(log)-1].Add(rl.window).Sub(now)
+		if !res.Allowed {
+			res.RetryAfter = log[0].Add(rl.window).Sub(now)
+		}
+	}
+
+	return res, nil
+}
+
+// sweep deletes the idle clients when a window has passed since the last sweep
+// and returns when the clients were last swept.
+func sweep[T any](clients map[string]T, sweptAt, now time.Time, window time.Duration, idle func(T) bool) time.Time {
+	if now.Sub(sweptAt) < window {
+		return sweptAt
+	}
+
+	for key, client := range clients {
+		if idle(client) {
+			delete(clients, key)
+		}
+	}
+
+	return now
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -1,12 +1,91 @@
 package ratelimiter
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown rate limiter algorithm")
+
+type Algorithm string
+
+const (
+	SlidingLog    Algorithm = "sliding-log"
+	SlidingWindow Algorithm = "sliding-window"
+	TokenBucket   Algorithm = "token-bucket"
+)
+
+// Result describes the client's quota after a call to Allow, it is what the
+// RateLimit-* response headers are built from.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full quota is available again.
+	Reset time.Duration
+	// RetryAfter is how long a rejected client has to wait for its next request.
+	RetryAfter time.Duration
+}
 
 type Limiter interface {
-	Allow(ip string) (bool, time.Duration)
+	Allow(ctx context.Context, key string) (Result, error)
 }
+
 type Config struct {
 	RequestsPerTimeFrame int
 	TimeFrame            time.Duration
 	Enabled              bool
+	// Algorithm picks the limiter, defaults to TokenBucket.
+	Algorithm Algorithm
+}
+
+// New builds the limiter for a route policy. With a redis client the counts are
+// shared by every replica, otherwise each replica keeps its own in process with
+// the same algorithm. name namespaces the redis counts so that policies don't
+// eat into each other's limits.
+func New(rdb *redis.Client, name string, cfg Config) (Limiter, error) {
+	switch cfg.Algorithm {
+	case SlidingLog:
+		if rdb == nil {
+			return NewLocalSlidingLogLimiter(cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+		}
+		return NewSlidingLogLimiter(rdb, name, cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+	case SlidingWindow:
+		if rdb == nil {
+			return NewLocalSlidingWindowLimiter(cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+		}
+		return NewSlidingWindowLimiter(rdb, name, cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+	case TokenBucket, "":
+		if rdb == nil {
+			return NewLocalTokenBucketLimiter(cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+		}
+		return NewTokenBucketLimiter(rdb, name, cfg.RequestsPerTimeFrame, cfg.TimeFrame), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
+
+// runScript runs one of the limiter scripts, which all reply with
+// {allowed, remaining, reset ms, retry after ms}.
+func runScript(ctx context.Context, rdb *redis.Client, script *redis.Script, limit int, keys []string, args ...interface{}) (Result, error) {
+	reply, err := script.Run(ctx, rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(reply) != 4 {
+		return Result{}, fmt.Errorf("unexpected rate limiter reply %v", reply)
+	}
+
+	return Result{
+		Allowed:    reply[0] == 1,
+		Limit:      limit,
+		Remaining:  int(reply[1]),
+		Reset:      time.Duration(reply[2]) * time.Millisecond,
+		RetryAfter: time.Duration(reply[3]) * time.Millisecond,
+	}, nil
 }
@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingLogScript keeps the timestamp of every allowed request in a sorted set
+// and drops the ones that have left the window, all in one round trip so
+// concurrent replicas can't both take the last slot.
+var slidingLogScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset, retry = 0, 0
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+end
+if allowed == 0 then
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset, retry}
+`)
+
+type SlidingLogRateLimiter struct {
+	rdb    *redis.Client
+	name   string
+	limit  int
+	window time.Duration
+}
+
+func NewSlidingLogLimiter(rdb *redis.Client, name string, limit int, window time.Duration) *SlidingLogRateLimiter {
+	return &SlidingLogRateLimiter{
+		rdb:    rdb,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (rl *SlidingLogRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	cacheKey := fmt.Sprintf("ratelimit-%s-%s", rl.name, key)
+
+	return runScript(
+		ctx,
+		rl.rdb,
+		slidingLogScript,
+		rl.limit,
+		[]string{cacheKey},
+		now.UnixMilli(),
+		rl.window.Milliseconds(),
+		rl.limit,
+		// the member only has to be unique, the score is what gets compared
+		now.UnixNano(),
+	)
+}
@@ -0,0 +1,86 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript estimates the requests in the last window from the counts
+// of the current and previous fixed windows, weighting the previous one by how
+// much of it still overlaps. This smooths out the burst a plain fixed window
+// allows at its edges while only storing two counters per client.
+var slidingWindowScript = redis.NewScript(`
+local elapsed = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+
+local count = prev * (window - elapsed) / window + curr
+local allowed = 0
+if count + 1 <= limit then
+	curr = redis.call('INCR', KEYS[1])
+	redis.call('PEXPIRE', KEYS[1], window * 2)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = 0
+if curr > 0 then
+	reset = window - elapsed + window
+elseif prev > 0 then
+	reset = window - elapsed
+end
+
+local retry = 0
+if allowed == 0 then
+	if prev > 0 and curr + 1 <= limit then
+		-- wait for enough of the previous window to slide out
+		retry = math.ceil((window - elapsed) - (limit - 1 - curr) * window / prev)
+	else
+		retry = window - elapsed
+	end
+end
+
+return {allowed, math.max(0, math.floor(limit - count)), reset, retry}
+`)
+
+type SlidingWindowRateLimiter struct {
+	rdb    *redis.Client
+	name   string
+	limit  int
+	window time.Duration
+}
+
+func NewSlidingWindowLimiter(rdb *redis.Client, name string, limit int, window time.Duration) *SlidingWindowRateLimiter {
+	return &SlidingWindowRateLimiter{
+		rdb:    rdb,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (rl *SlidingWindowRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now().UnixMilli()
+	window := rl.window.Milliseconds()
+	current := now / window
+
+	return runScript(
+		ctx,
+		rl.rdb,
+		slidingWindowScript,
+		rl.limit,
+		[]string{
+			fmt.Sprintf("ratelimit-%s-%s-%d", rl.name, key, current),
+			fmt.Sprintf("ratelimit-%s-%s-%d", rl.name, key, current-1),
+		},
+		now-current*window,
+		window,
+		rl.limit,
+	)
+}
@@ -0,0 +1,73 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills the bucket for the time since the last request and
+// takes a token if there is one. Clients can burst up to the bucket size and
+// are then held to the refill rate, with no window edge to line up against.
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+type TokenBucketRateLimiter struct {
+	rdb      *redis.Client
+	name     string
+	capacity int
+	// refillRate is in tokens per millisecond
+	refillRate float64
+}
+
+// NewTokenBucketLimiter holds up to limit tokens and refills them at limit per
+// window, so the sustained rate matches the window based limiters.
+func NewTokenBucketLimiter(rdb *redis.Client, name string, limit int, window time.Duration) *TokenBucketRateLimiter {
+	return &TokenBucketRateLimiter{
+		rdb:        rdb,
+		name:       name,
+		capacity:   limit,
+		refillRate: float64(limit) / float64(window.Milliseconds()),
+	}
+}
+
+func (rl *TokenBucketRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	cacheKey := fmt.Sprintf("ratelimit-%s-%s", rl.name, key)
+
+	return runScript(
+		ctx,
+		rl.rdb,
+		tokenBucketScript,
+		rl.capacity,
+		[]string{cacheKey},
+		time.Now().UnixMilli(),
+		rl.capacity,
+		rl.refillRate,
+	)
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	ActivityWorkout        = "workout"
+	ActivityPersonalRecord = "personal_record"
+	ActivityMeal           = "meal"
+)
+
+var ActivityTypes = []string{ActivityWorkout, ActivityPersonalRecord, ActivityMeal}
+
+type Activity struct {
+	ID          uuid.UUID       `json:"id"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Username    string          `json:"username,omitempty"`
+	Type        string          `json:"type"`
+	ReferenceID uuid.UUID       `json:"reference_id"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   string          `json:"created_at"`
+}
+
+type FeedPage struct {
+	Activities []Activity `json:"activities"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type activityCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+type ActivityStore struct {
+	db *sql.DB
+}
+
+func (s *ActivityStore) Create(ctx context.Context, activity *Activity) error {
+	query := `
+		INSERT INTO activities (user_id, type, reference_id, data)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		activity.UserID,
+		activity.Type,
+		activity.ReferenceID,
+		string(activity.Data),
+	).Scan(
+		&activity.ID,
+		&activity.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteByReference removes every activity generated by the given workout or
+// meal, used when the source is deleted or no longer shared.
+func (s *ActivityStore) DeleteByReference(ctx context.Context, referenceID uuid.UUID) error {
+	query := `
+		DELETE FROM activities
+		WHERE reference_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, referenceID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserFeed returns the activity of the users that userID follows, newest
+// first, skipping activity types the author has chosen not to share.
+func (s *ActivityStore) GetUserFeed(ctx context.Context, userID uuid.UUID, fq PaginatedFeedQuery) (*FeedPage, error) {
+	var cursor activityCursor
+	var cursorCreatedAt *time.Time
+	if fq.Cursor != "" {
+		if err := decodeCursor(fq.Cursor, &cursor); err != nil {
+			return nil, err
+		}
+
+		cursorCreatedAt = &cursor.CreatedAt
+	}
+
+	types := fq.Types
+	if len(types) == 0 {
+		types = ActivityTypes
+	}
+
+	query := `
+		SELECT a.id, a.user_id, u.username, a.type, a.reference_id, a.data, a.created_at
+		FROM activities a
+		JOIN followers f ON f.user_id = a.user_id AND f.follower_id = $1
+		JOIN users u ON u.id = a.user_id
+		WHERE a.type::text = ANY ($2)
+			AND (a.type <> 'workout' OR u.share_workouts)
+			AND (a.type <> 'personal_record' OR u.share_records)
+			AND ($3::timestamptz IS NULL OR (a.created_at, a.id) < ($3, $4))
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// fetch one extra row to know whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(types), cursorCreatedAt, cursor.ID, fq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &FeedPage{Activities: []Activity{}}
+	var last activityCursor
+	for rows.Next() {
+		var activity Activity
+		var data []byte
+		var createdAt time.Time
+		err := rows.Scan(
+			&activity.ID,
+			&activity.UserID,
+			&activity.Username,
+			&activity.Type,
+			&activity.ReferenceID,
+			&data,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Activities) == fq.Limit {
+			next, err := encodeCursor(last)
+			if err != nil {
+				return nil, err
+			}
+
+			page.NextCursor = next
+			break
+		}
+
+		activity.Data = data
+		activity.CreatedAt = createdAt.Format(time.RFC3339Nano)
+		page.Activities = append(page.Activities, activity)
+		last = activityCursor{CreatedAt: createdAt, ID: activity.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
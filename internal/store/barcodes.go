@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidBarcode   = errors.New("barcode must be a valid EAN-13 or UPC-A code")
+	ErrDuplicateBarcode = errors.New("a food with that barcode already exists")
+)
+
+// NormalizeBarcode checks an EAN-13 or UPC-A barcode, spaces and dashes
+// allowed, and returns it as EAN-13. A UPC-A code is the EAN-13 code with a
+// leading zero, so either form of a product scans to the same food.
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+
+	switch len(code) {
+	case 12:
+		code = "0" + code
+	case 13:
+	default:
+		return "", ErrInvalidBarcode
+	}
+
+	// weights alternate 1 and 3 from the left, the last digit is the check digit
+	sum := 0
+	for i, r := range code {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidBarcode
+		}
+
+		digit := int(r - '0')
+		if i == 12 {
+			if (10-sum%10)%10 != digit {
+				return "", ErrInvalidBarcode
+			}
+			break
+		}
+
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return code, nil
+}
+
+// GetByBarcode finds the food with the given normalised barcode among those
+// the user can see, preferring the user's own version of a product.
+func (s *FoodStore) GetByBarcode(ctx context.Context, barcode string, viewerID uuid.UUID) (*Food, error) {
+	query := `
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at
+		FROM foods
+		WHERE barcode = $1 AND ((visibility = 'public' AND status = 'approved') OR (user_id = $2 AND status <> 'merged'))
+		ORDER BY user_id IS NOT DISTINCT FROM $2 DESC, verified DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var food Food
+	err := s.db.QueryRowContext(ctx, query, barcode, viewerID).Scan(
+		&food.ID,
+		&food.Name,
+		&food.Description,
+		&food.Calories,
+		&food.Protein,
+		&food.Carbs,
+		&food.Fat,
+		&food.Fiber,
+		&food.Micronutrients.Sugar,
+		&food.Micronutrients.SaturatedFat,
+		&food.Micronutrients.Sodium,
+		&food.Micronutrients.Cholesterol,
+		&food.Micronutrients.Potassium,
+		&food.Micronutrients.VitaminA,
+		&food.Micronutrients.VitaminC,
+		&food.Micronutrients.VitaminD,
+		&food.Density,
+		&food.Brand,
+		&food.ServingSize,
+		&food.ServingUnit,
+		&food.Barcode,
+		&food.Verified,
+		&food.Visibility,
+		&food.Status,
+		&food.RejectionReason,
+		&food.UserID,
+		&food.CreatedAt,
+		&food.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &food, nil
+}
+
+func barcodeValue(barcode *string) string {
+	if barcode == nil {
+		return ""
+	}
+
+	return *barcode
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeBarcode(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    string
+		wantErr error
+	}{
+		{"ean-13", "4006381333931", "4006381333931", nil},
+		{"upc-a gets a leading zero", "036000291452", "0036000291452", nil},
+		{"upc-a written as ean-13", "0036000291452", "0036000291452", nil},
+		{"spaces and dashes", "4 006381-333931", "4006381333931", nil},
+		{"check digit of zero", "0000000000000", "0000000000000", nil},
+		{"wrong check digit", "4006381333932", "", ErrInvalidBarcode},
+		{"wrong upc-a check digit", "036000291453", "", ErrInvalidBarcode},
+		{"too short", "12345678", "", ErrInvalidBarcode},
+		{"too long", "40063813339310", "", ErrInvalidBarcode},
+		{"letters", "40063813339a1", "", ErrInvalidBarcode},
+		{"empty", "", "", ErrInvalidBarcode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeBarcode(tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeBarcode(%q) error = %v, want %v", tt.code, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("NormalizeBarcode(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
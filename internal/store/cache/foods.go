@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/zondaf12/workout-app-backend/internal/store"
+)
+
+type FoodStore struct {
+	rdb *redis.Client
+}
+
+const FoodSuggestionsExpTime = time.Hour
+
+// suggestionsKey holds every cached suggestion query of a user as fields of one
+// hash, so that logging a food can drop them all at once.
+func suggestionsKey(userID uuid.UUID) string {
+	return fmt.Sprintf("food-suggestions-%s", userID)
+}
+
+func suggestionsField(q store.FoodSuggestionQuery) string {
+	location := "UTC"
+	if q.Location != nil {
+		location = q.Location.String()
+	}
+
+	return fmt.Sprintf("%s|%s|%s", q.MealName, q.TimeOfDay, location)
+}
+
+func (s *FoodStore) GetSuggestions(ctx context.Context, userID uuid.UUID, q store.FoodSuggestionQuery) (*store.FoodSuggestions, error) {
+	data, err := s.rdb.HGet(ctx, suggestionsKey(userID), suggestionsField(q)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var suggestions store.FoodSuggestions
+	if err := json.Unmarshal([]byte(data), &suggestions); err != nil {
+		return nil, err
+	}
+
+	return &suggestions, nil
+}
+
+func (s *FoodStore) SetSuggestions(ctx context.Context, userID uuid.UUID, q store.FoodSuggestionQuery, suggestions *store.FoodSuggestions) error {
+	json, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	key := suggestionsKey(userID)
+
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, key, suggestionsField(q), json)
+	pipe.Expire(ctx, key, FoodSuggestionsExpTime)
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (s *FoodStore) DeleteSuggestions(ctx context.Context, userID uuid.UUID) error {
+	return s.rdb.Del(ctx, suggestionsKey(userID)).Err()
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -14,10 +15,21 @@ type Storage struct {
 		Set(context.Context, *store.User) error
 		Delete(context.Context, uuid.UUID)
 	}
+	Tokens interface {
+		IsRevoked(context.Context, uuid.UUID) (bool, bool, error)
+		SetRevoked(context.Context, uuid.UUID, bool, time.Duration) error
+	}
+	Foods interface {
+		GetSuggestions(context.Context, uuid.UUID, store.FoodSuggestionQuery) (*store.FoodSuggestions, error)
+		SetSuggestions(context.Context, uuid.UUID, store.FoodSuggestionQuery, *store.FoodSuggestions) error
+		DeleteSuggestions(context.Context, uuid.UUID) error
+	}
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rbd},
+		Users:  &UserStore{rdb: rbd},
+		Tokens: &TokenStore{rdb: rbd},
+		Foods:  &FoodStore{rdb: rbd},
 	}
 }
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+type TokenStore struct {
+	rdb *redis.Client
+}
+
+// TokenCheckExpTime bounds how long a "not revoked" answer is trusted, revoked
+// answers are kept until the token itself expires.
+const TokenCheckExpTime = time.Minute
+
+// IsRevoked reports the cached denylist status of a jti. found is false on a
+// cache miss, in which case the database has to be asked.
+func (s *TokenStore) IsRevoked(ctx context.Context, jti uuid.UUID) (revoked bool, found bool, err error) {
+	cacheKey := fmt.Sprintf("revoked-jti-%s", jti)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, err
+	}
+
+	return data == "1", true, nil
+}
+
+func (s *TokenStore) SetRevoked(ctx context.Context, jti uuid.UUID, revoked bool, exp time.Duration) error {
+	cacheKey := fmt.Sprintf("revoked-jti-%s", jti)
+
+	value := "0"
+	if revoked {
+		value = "1"
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, value, exp).Err()
+}
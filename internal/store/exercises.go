@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Exercise struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	MuscleGroups []string   `json:"muscle_groups"`
+	Equipment    string     `json:"equipment"`
+	MovementType string     `json:"movement_type"`
+	UserID       *uuid.UUID `json:"user_id"`
+	CreatedAt    string     `json:"created_at"`
+	UpdatedAt    string     `json:"updated_at"`
+}
+
+type ExerciseFilter struct {
+	Search       string
+	MuscleGroup  string
+	Equipment    string
+	MovementType string
+}
+
+type ExerciseStore struct {
+	db *sql.DB
+}
+
+func (s *ExerciseStore) Create(ctx context.Context, exercise *Exercise) error {
+	query := `
+		INSERT INTO exercises (name, description, muscle_groups, equipment, movement_type, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		exercise.Name,
+		exercise.Description,
+		pq.Array(exercise.MuscleGroups),
+		exercise.Equipment,
+		exercise.MovementType,
+		exercise.UserID,
+	).Scan(
+		&exercise.ID,
+		&exercise.CreatedAt,
+		&exercise.UpdatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *ExerciseStore) GetByID(ctx context.Context, id uuid.UUID) (*Exercise, error) {
+	query := `
+		SELECT id, name, description, muscle_groups, equipment, movement_type, user_id, created_at, updated_at
+		FROM exercises
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exercise Exercise
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&exercise.ID,
+		&exercise.Name,
+		&exercise.Description,
+		pq.Array(&exercise.MuscleGroups),
+		&exercise.Equipment,
+		&exercise.MovementType,
+		&exercise.UserID,
+		&exercise.CreatedAt,
+		&exercise.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &exercise, nil
+}
+
+func (s *ExerciseStore) List(ctx context.Context, filter ExerciseFilter) ([]Exercise, error) {
+	query := `
+		SELECT id, name, description, muscle_groups, equipment, movement_type, user_id, created_at, updated_at
+		FROM exercises
+		WHERE ($1 = '' OR name ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR $2 = ANY (muscle_groups))
+			AND ($3 = '' OR equipment = $3)
+			AND ($4 = '' OR movement_type::text = $4)
+		ORDER BY name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Search, filter.MuscleGroup, filter.Equipment, filter.MovementType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []Exercise{}
+	for rows.Next() {
+		var exercise Exercise
+		err := rows.Scan(
+			&exercise.ID,
+			&exercise.Name,
+			&exercise.Description,
+			pq.Array(&exercise.MuscleGroups),
+			&exercise.Equipment,
+			&exercise.MovementType,
+			&exercise.UserID,
+			&exercise.CreatedAt,
+			&exercise.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		exercises = append(exercises, exercise)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exercises, nil
+}
@@ -0,0 +1,453 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+var (
+	ErrMergeIntoSelf       = errors.New("a food can't be merged into itself")
+	ErrMergeTarget         = errors.New("foods can only be merged into an approved public food")
+	ErrMergeUnits          = errors.New("the food's units don't convert to those of the food it is merged into")
+	ErrMergePortions       = errors.New("both foods have a portion of the same name that measures differently")
+	ErrDetectionInProgress = errors.New("duplicate detection is already running")
+)
+
+const (
+	// duplicateSimilarity is the trigram similarity of name and brand above
+	// which two foods with close nutrition are reported as duplicates.
+	duplicateSimilarity = "0.4"
+
+	// duplicateDetectionTimeout bounds a full detection run, which compares
+	// the whole public catalog.
+	duplicateDetectionTimeout = time.Minute
+
+	// duplicateDetectionLock is the advisory lock key a detection run holds,
+	// so replicas of the api don't compare the catalog at the same time.
+	duplicateDetectionLock = 7_300_041
+)
+
+// DuplicateCluster is a group of foods that were found to be duplicates of
+// one another, directly or through other foods in the group.
+type DuplicateCluster struct {
+	Foods      []Food  `json:"foods"`
+	Similarity float64 `json:"similarity"`
+}
+
+// DetectDuplicates replaces the duplicate candidates with pairs of public foods
+// whose name and brand are similar and whose nutrition per unit is within 10%,
+// or a small absolute slack for values near zero. It returns the pairs found,
+// or ErrDetectionInProgress when a run is already going elsewhere.
+func (s *FoodStore) DetectDuplicates(ctx context.Context) (int, error) {
+	query := `
+		INSERT INTO food_duplicate_candidates (food_id, duplicate_id, similarity)
+		SELECT a.id, b.id, similarity(lower(a.name || ' ' || COALESCE(a.brand, '')), lower(b.name || ' ' || COALESCE(b.brand, '')))
+		FROM foods a
+		JOIN foods b ON a.id < b.id
+			AND lower(a.name || ' ' || COALESCE(a.brand, '')) % lower(b.name || ' ' || COALESCE(b.brand, ''))
+		WHERE a.visibility = 'public' AND b.visibility = 'public'
+			AND a.status NOT IN ('rejected', 'merged') AND b.status NOT IN ('rejected', 'merged')
+			AND lower(a.serving_unit) = lower(b.serving_unit)
+			AND abs(a.calories / a.serving_size - b.calories / b.serving_size)
+				<= greatest(0.1 * greatest(a.calories / a.serving_size, b.calories / b.serving_size), 0.05)
+			AND abs(a.protein / a.serving_size - b.protein / b.serving_size)
+				<= greatest(0.1 * greatest(a.protein / a.serving_size, b.protein / b.serving_size), 0.01)
+			AND abs(a.carbs / a.serving_size - b.carbs / b.serving_size)
+				<= greatest(0.1 * greatest(a.carbs / a.serving_size, b.carbs / b.serving_size), 0.01)
+			AND abs(a.fat / a.serving_size - b.fat / b.serving_size)
+				<= greatest(0.1 * greatest(a.fat / a.serving_size, b.fat / b.serving_size), 0.01)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, duplicateDetectionTimeout)
+	defer cancel()
+
+	var found int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// released with the transaction
+		var locked bool
+		if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, duplicateDetectionLock).Scan(&locked); err != nil {
+			return err
+		}
+
+		if !locked {
+			return ErrDetectionInProgress
+		}
+
+		// scoped to the transaction, the % operator uses this threshold
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, duplicateSimilarity); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM food_duplicate_candidates`); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		found, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(found), nil
+}
+
+// GetDuplicateClusters groups the duplicate candidates into clusters, most
+// similar first. Foods in a cluster are listed oldest first.
+func (s *FoodStore) GetDuplicateClusters(ctx context.Context) ([]DuplicateCluster, error) {
+	query := `
+		SELECT food_id, duplicate_id, similarity
+		FROM food_duplicate_candidates
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// union-find over the pairs, a cluster is keyed by its root food
+	parent := map[uuid.UUID]uuid.UUID{}
+	var find func(uuid.UUID) uuid.UUID
+	find = func(id uuid.UUID) uuid.UUID {
+		if p, ok := parent[id]; ok && p != id {
+			root := find(p)
+			parent[id] = root
+			return root
+		}
+
+		parent[id] = id
+		return id
+	}
+
+	similarity := map[uuid.UUID]float64{}
+	type pair struct {
+		a, b       uuid.UUID
+		similarity float64
+	}
+	var pairs []pair
+	for rows.Next() {
+		var p pair
+		if err := rows.Scan(&p.a, &p.b, &p.similarity); err != nil {
+			return nil, err
+		}
+
+		parent[find(p.a)] = find(p.b)
+		pairs = append(pairs, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range pairs {
+		root := find(p.a)
+		similarity[root] = max(similarity[root], p.similarity)
+	}
+
+	ids := make([]string, 0, len(parent))
+	for id := range parent {
+		ids = append(ids, id.String())
+	}
+
+	foods, err := s.getByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[uuid.UUID]*DuplicateCluster{}
+	clusters := []*DuplicateCluster{}
+	for _, food := range foods {
+		root := find(food.ID)
+		cluster, ok := index[root]
+		if !ok {
+			cluster = &DuplicateCluster{Similarity: similarity[root]}
+			index[root] = cluster
+			clusters = append(clusters, cluster)
+		}
+
+		cluster.Foods = append(cluster.Foods, food)
+	}
+
+	result := []DuplicateCluster{}
+	for _, cluster := range clusters {
+		// the other foods may have been merged or deleted since detection ran
+		if len(cluster.Foods) > 1 {
+			result = append(result, *cluster)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Similarity > result[j].Similarity
+	})
+
+	return result, nil
+}
+
+// getByIDs fetches the given foods, oldest first.
+func (s *FoodStore) getByIDs(ctx context.Context, ids []string) ([]Food, error) {
+	query := `
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at
+		FROM foods
+		WHERE id = ANY($1::uuid[])
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foods := []Food{}
+	for rows.Next() {
+		var food Food
+		err := rows.Scan(
+			&food.ID,
+			&food.Name,
+			&food.Description,
+			&food.Calories,
+			&food.Protein,
+			&food.Carbs,
+			&food.Fat,
+			&food.Fiber,
+			&food.Micronutrients.Sugar,
+			&food.Micronutrients.SaturatedFat,
+			&food.Micronutrients.Sodium,
+			&food.Micronutrients.Cholesterol,
+			&food.Micronutrients.Potassium,
+			&food.Micronutrients.VitaminA,
+			&food.Micronutrients.VitaminC,
+			&food.Micronutrients.VitaminD,
+			&food.Density,
+			&food.Brand,
+			&food.ServingSize,
+			&food.ServingUnit,
+			&food.Barcode,
+			&food.Verified,
+			&food.Visibility,
+			&food.Status,
+			&food.RejectionReason,
+			&food.UserID,
+			&food.CreatedAt,
+			&food.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		foods = append(foods, food)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return foods, nil
+}
+
+// Merge folds the food fromID into intoID, which has to be an approved public
+// food whose serving unit amounts of fromID convert to. Meal entries, recipes,
+// templates and favourites move to intoID and fromID's id is left redirecting
+// to it. The merged food itself is kept, with its revisions and import key, so
+// entries logged against it keep their values and a re-import doesn't bring it
+// back.
+func (s *FoodStore) Merge(ctx context.Context, fromID, intoID, moderatorID uuid.UUID) error {
+	if fromID == intoID {
+		return ErrMergeIntoSelf
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		from, into, err := s.lockMergedFoods(ctx, tx, fromID, intoID)
+		if err != nil {
+			return err
+		}
+
+		// other users' recipes and templates would end up pointing at a food they can't see
+		if into.Visibility != FoodPublic || into.Status != FoodApproved {
+			return ErrMergeTarget
+		}
+
+		if err := s.checkMergeUnits(ctx, tx, from, into); err != nil {
+			return err
+		}
+
+		queries := []string{
+			// entries keep the values of the food they were logged against
+			`UPDATE meal_entries SET logged_food_id = COALESCE(logged_food_id, food_id), food_id = $2 WHERE food_id = $1`,
+			// replaced rather than repointed, recipe entries logged before keep the merged food
+			`UPDATE recipe_ingredients SET replaced_at = CURRENT_TIMESTAMP WHERE food_id = $1 AND replaced_at IS NULL`,
+			// servings of the merged food are a different amount of the surviving one
+			`INSERT INTO recipe_ingredients (recipe_id, food_id, position, amount, unit)
+				SELECT ri.recipe_id, $2, ri.position,
+					CASE WHEN ri.unit = 'serving' THEN ri.amount * f.serving_size ELSE ri.amount END,
+					CASE WHEN ri.unit = 'serving' THEN f.serving_unit ELSE ri.unit END
+				FROM recipe_ingredients ri
+				JOIN foods f ON f.id = ri.food_id
+				WHERE ri.food_id = $1 AND ri.replaced_at = CURRENT_TIMESTAMP`,
+			`UPDATE meal_template_entries e SET food_id = $2,
+				amount = CASE WHEN lower(trim(e.serving_unit)) = 'serving' THEN e.amount * f.serving_size ELSE e.amount END,
+				serving_unit = CASE WHEN lower(trim(e.serving_unit)) = 'serving' THEN f.serving_unit ELSE e.serving_unit END
+				FROM foods f
+				WHERE f.id = $1 AND e.food_id = $1`,
+			`INSERT INTO favourite_foods (user_id, food_id, created_at)
+				SELECT user_id, $2, created_at FROM favourite_foods WHERE food_id = $1
+				ON CONFLICT (user_id, food_id) DO NOTHING`,
+			`DELETE FROM favourite_foods WHERE food_id = $1`,
+			`DELETE FROM food_duplicate_candidates WHERE food_id = $1 OR duplicate_id = $1`,
+			`UPDATE food_redirects SET food_id = $2 WHERE food_id = $1`,
+			// checkMergeUnits made sure portions of the same name measure the same
+			`INSERT INTO food_portions (food_id, name, amount, unit)
+				SELECT $2, name, amount, unit FROM food_portions WHERE food_id = $1
+				ON CONFLICT (food_id, name) DO NOTHING`,
+		}
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, fromID, intoID); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO food_redirects (from_id, food_id, merged_by) VALUES ($1, $2, $3)
+		`, fromID, intoID, moderatorID)
+		if err != nil {
+			return err
+		}
+
+		// the barcode and density are copied to the surviving food unless it
+		// has its own, the merged food no longer holds the barcode
+		_, err = tx.ExecContext(ctx, `
+			UPDATE foods SET status = 'merged', verified = false, updated_at = CURRENT_TIMESTAMP WHERE id = $1
+		`, fromID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE foods SET barcode = COALESCE(barcode, $2), density = COALESCE(density, $3) WHERE id = $1
+		`, intoID, from.Barcode, from.Density)
+		return err
+	})
+}
+
+// lockMergedFoods loads both foods of a merge and locks them so neither is
+// edited or merged elsewhere meanwhile.
+func (s *FoodStore) lockMergedFoods(ctx context.Context, tx *sql.Tx, fromID, intoID uuid.UUID) (*Food, *Food, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, serving_size, serving_unit, density, barcode, visibility, status
+		FROM foods WHERE id IN ($1, $2) AND status <> 'merged'
+		FOR UPDATE
+	`, fromID, intoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var from, into *Food
+	for rows.Next() {
+		food := &Food{}
+		if err := rows.Scan(&food.ID, &food.ServingSize, &food.ServingUnit, &food.Density, &food.Barcode, &food.Visibility, &food.Status); err != nil {
+			return nil, nil, err
+		}
+
+		if food.ID == fromID {
+			from = food
+		} else {
+			into = food
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if from == nil || into == nil {
+		return nil, nil, ErrNotFound
+	}
+
+	return from, into, nil
+}
+
+// checkMergeUnits makes sure everything measured in from's units still means
+// the same amount of into: from's serving unit and portions have to convert to
+// into's serving unit, and a portion both foods have must measure the same.
+func (s *FoodStore) checkMergeUnits(ctx context.Context, tx *sql.Tx, from, into *Food) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT food_id, name, amount, unit FROM food_portions WHERE food_id IN ($1, $2)
+	`, from.ID, into.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var fromPortions []FoodPortion
+	intoPortions := make(map[string]FoodPortion)
+	for rows.Next() {
+		var portion FoodPortion
+		if err := rows.Scan(&portion.FoodID, &portion.Name, &portion.Amount, &portion.Unit); err != nil {
+			return err
+		}
+
+		if portion.FoodID == from.ID {
+			fromPortions = append(fromPortions, portion)
+		} else {
+			intoPortions[portion.Name] = portion
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// the surviving food takes the merged one's density when it has none
+	merged := *into
+	if merged.Density == nil {
+		merged.Density = from.Density
+	}
+
+	portions := make([]FoodPortion, 0, len(intoPortions)+len(fromPortions))
+	for _, portion := range intoPortions {
+		portions = append(portions, portion)
+	}
+	for _, portion := range fromPortions {
+		existing, ok := intoPortions[portion.Name]
+		if !ok {
+			portions = append(portions, portion)
+			continue
+		}
+
+		if existing.Amount != portion.Amount || units.Normalize(existing.Unit) != units.Normalize(portion.Unit) {
+			return ErrMergePortions
+		}
+	}
+
+	measure := merged.Measure(portions)
+	if !measure.Supports(from.ServingUnit) {
+		return ErrMergeUnits
+	}
+	for _, portion := range fromPortions {
+		if !measure.Supports(portion.Name) {
+			return ErrMergeUnits
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type ImportResult int
+
+const (
+	ImportInserted ImportResult = iota
+	ImportUpdated
+	ImportUnchanged
+)
+
+// Import upserts a food from an external dataset, keyed by its source and
+// the id it has there. Imported foods are public and verified. An update
+// keeps the version it replaces like any other edit, and foods whose values
+// haven't changed are left alone, as are foods merged into another one.
+func (s *FoodStore) Import(ctx context.Context, source, externalID string, food *Food) (ImportResult, error) {
+	food.Visibility = FoodPublic
+	food.Status = FoodApproved
+	food.Verified = true
+	food.RejectionReason = ""
+
+	result := ImportUnchanged
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		existing, err := s.getImported(ctx, tx, source, externalID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				result = ImportInserted
+				return s.createImported(ctx, tx, source, externalID, food)
+			}
+
+			return err
+		}
+
+		// the food it was merged into stands in for it
+		if existing.Status == FoodMerged {
+			return nil
+		}
+
+		food.ID = existing.ID
+		food.UserID = existing.UserID
+		food.CreatedAt = existing.CreatedAt
+		food.UpdatedAt = existing.UpdatedAt
+		if len(foodChanges(existing, food)) == 0 && existing.Status == FoodApproved && existing.Verified {
+			return nil
+		}
+
+		result = ImportUpdated
+		if err := s.saveRevision(ctx, tx, food.ID, nil); err != nil {
+			return err
+		}
+
+		return s.update(ctx, tx, food)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// getImported locks the food imported under the given key.
+func (s *FoodStore) getImported(ctx context.Context, tx *sql.Tx, source, externalID string) (*Food, error) {
+	query := `
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, status, user_id, created_at, updated_at
+		FROM foods
+		WHERE source = $1 AND external_id = $2
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var food Food
+	err := tx.QueryRowContext(ctx, query, source, externalID).Scan(
+		&food.ID,
+		&food.Name,
+		&food.Description,
+		&food.Calories,
+		&food.Protein,
+		&food.Carbs,
+		&food.Fat,
+		&food.Fiber,
+		&food.Micronutrients.Sugar,
+		&food.Micronutrients.SaturatedFat,
+		&food.Micronutrients.Sodium,
+		&food.Micronutrients.Cholesterol,
+		&food.Micronutrients.Potassium,
+		&food.Micronutrients.VitaminA,
+		&food.Micronutrients.VitaminC,
+		&food.Micronutrients.VitaminD,
+		&food.Density,
+		&food.Brand,
+		&food.ServingSize,
+		&food.ServingUnit,
+		&food.Barcode,
+		&food.Verified,
+		&food.Status,
+		&food.UserID,
+		&food.CreatedAt,
+		&food.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &food, nil
+}
+
+func (s *FoodStore) createImported(ctx context.Context, tx *sql.Tx, source, externalID string, food *Food) error {
+	query := `
+		INSERT INTO foods (name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d,
+			density, brand, serving_size, serving_unit, barcode, visibility, status, verified, source, external_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		food.Name,
+		food.Description,
+		food.Calories,
+		food.Protein,
+		food.Carbs,
+		food.Fat,
+		food.Fiber,
+		food.Micronutrients.Sugar,
+		food.Micronutrients.SaturatedFat,
+		food.Micronutrients.Sodium,
+		food.Micronutrients.Cholesterol,
+		food.Micronutrients.Potassium,
+		food.Micronutrients.VitaminA,
+		food.Micronutrients.VitaminC,
+		food.Micronutrients.VitaminD,
+		food.Density,
+		food.Brand,
+		food.ServingSize,
+		food.ServingUnit,
+		food.Barcode,
+		food.Visibility,
+		food.Status,
+		food.Verified,
+		source,
+		externalID,
+	).Scan(
+		&food.ID,
+		&food.CreatedAt,
+		&food.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type ModerationAction string
+
+const (
+	ModerationApprove ModerationAction = "approve"
+	ModerationReject  ModerationAction = "reject"
+	ModerationEdit    ModerationAction = "edit"
+)
+
+// FoodModerationAction records a moderator's decision on a food. Changes holds
+// the fields an edit touched, keyed by column with their old and new values.
+type FoodModerationAction struct {
+	ID          uuid.UUID        `json:"id"`
+	FoodID      uuid.UUID        `json:"food_id"`
+	ModeratorID *uuid.UUID       `json:"moderator_id"`
+	Action      ModerationAction `json:"action"`
+	Reason      string           `json:"reason,omitempty"`
+	Changes     json.RawMessage  `json:"changes"`
+	CreatedAt   string           `json:"created_at"`
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// foodChanges lists the editable fields that differ between before and after.
+func foodChanges(before, after *Food) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	add := func(field string, from, to any) {
+		if from != to {
+			changes[field] = FieldChange{From: from, To: to}
+		}
+	}
+
+	add("name", before.Name, after.Name)
+	add("description", before.Description, after.Description)
+	add("calories", before.Calories, after.Calories)
+	add("protein", before.Protein, after.Protein)
+	add("carbs", before.Carbs, after.Carbs)
+	add("fat", before.Fat, after.Fat)
+	add("fiber", before.Fiber, after.Fiber)
+	add("micronutrients", before.Micronutrients, after.Micronutrients)
+	add("brand", before.Brand, after.Brand)
+	add("serving_size", before.ServingSize, after.ServingSize)
+	add("serving_unit", before.ServingUnit, after.ServingUnit)
+	add("barcode", barcodeValue(before.Barcode), barcodeValue(after.Barcode))
+	add("density", densityValue(before.Density), densityValue(after.Density))
+
+	return changes
+}
+
+// GetPending lists the public foods waiting for a moderator, oldest first.
+// Private foods never need approval since nobody else can see them.
+func (s *FoodStore) GetPending(ctx context.Context, fq PaginatedQuery) (*FoodPage, error) {
+	query := `
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at,
+			created_at
+		FROM foods
+		WHERE status = 'pending' AND visibility = 'public'
+			AND ($1::timestamptz IS NULL OR (created_at, id) > ($1, $2))
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3
+	`
+
+	countQuery := `SELECT COUNT(*) FROM foods WHERE status = 'pending' AND visibility = 'public'`
+
+	return s.listFoods(ctx, query, countQuery, nil, fq)
+}
+
+// Approve publishes the food and marks it as verified. It fails with
+// ErrDuplicateBarcode when an approved food already has its barcode.
+func (s *FoodStore) Approve(ctx context.Context, food *Food, moderatorID uuid.UUID) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		food.Status = FoodApproved
+		food.Verified = true
+		food.RejectionReason = ""
+
+		if err := s.setStatus(ctx, tx, food); err != nil {
+			return err
+		}
+
+		return s.logAction(ctx, tx, food.ID, moderatorID, ModerationApprove, "", nil)
+	})
+}
+
+// Reject keeps the food out of other users' searches, the reason is shown to
+// whoever submitted it.
+func (s *FoodStore) Reject(ctx context.Context, food *Food, moderatorID uuid.UUID, reason string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		food.Status = FoodRejected
+		food.Verified = false
+		food.RejectionReason = reason
+
+		if err := s.setStatus(ctx, tx, food); err != nil {
+			return err
+		}
+
+		return s.logAction(ctx, tx, food.ID, moderatorID, ModerationReject, reason, nil)
+	})
+}
+
+// ModeratorEdit saves a moderator's corrections to a food, keeping the version
+// it replaces, and logs what changed. Its moderation status is left as it was.
+func (s *FoodStore) ModeratorEdit(ctx context.Context, before, after *Food, moderatorID uuid.UUID) error {
+	changes := foodChanges(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.saveRevision(ctx, tx, after.ID, &moderatorID); err != nil {
+			return err
+		}
+
+		if err := s.update(ctx, tx, after); err != nil {
+			return err
+		}
+
+		return s.logAction(ctx, tx, after.ID, moderatorID, ModerationEdit, "", changes)
+	})
+}
+
+func (s *FoodStore) setStatus(ctx context.Context, tx *sql.Tx, food *Food) error {
+	query := `
+		UPDATE foods
+		SET status = $1, verified = $2, rejection_reason = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		food.Status,
+		food.Verified,
+		food.RejectionReason,
+		food.ID,
+	).Scan(
+		&food.UpdatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateBarcode
+		}
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FoodStore) logAction(ctx context.Context, tx *sql.Tx, foodID, moderatorID uuid.UUID, action ModerationAction, reason string, changes map[string]FieldChange) error {
+	query := `
+		INSERT INTO food_moderation_actions (food_id, moderator_id, action, reason, changes)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if changes == nil {
+		changes = map[string]FieldChange{}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = tx.ExecContext(ctx, query, foodID, moderatorID, action, reason, string(data))
+	return err
+}
+
+// GetModerationHistory lists every moderation action taken on the food, most
+// recent first.
+func (s *FoodStore) GetModerationHistory(ctx context.Context, foodID uuid.UUID) ([]FoodModerationAction, error) {
+	query := `
+		SELECT id, food_id, moderator_id, action, reason, changes, created_at
+		FROM food_moderation_actions
+		WHERE food_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, foodID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []FoodModerationAction{}
+	for rows.Next() {
+		var action FoodModerationAction
+		var changes []byte
+		err := rows.Scan(
+			&action.ID,
+			&action.FoodID,
+			&action.ModeratorID,
+			&action.Action,
+			&action.Reason,
+			&changes,
+			&action.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		action.Changes = changes
+		actions = append(actions, action)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
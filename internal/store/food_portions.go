@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+var (
+	ErrDuplicatePortion = errors.New("the food already has a portion with that name")
+	ErrPortionInUse     = errors.New("the portion is used by logged meal entries or recipes")
+)
+
+// FoodPortion is a household measure of a food, Amount of Unit where Unit is
+// a unit the food already converts from, e.g. 1 slice = 28 g.
+type FoodPortion struct {
+	ID        uuid.UUID `json:"id"`
+	FoodID    uuid.UUID `json:"food_id"`
+	Name      string    `json:"name"`
+	Amount    float64   `json:"amount"`
+	Unit      string    `json:"unit"`
+	CreatedAt string    `json:"created_at"`
+}
+
+// Measure describes how amounts of the food convert to its serving unit.
+func (f *Food) Measure(portions []FoodPortion) units.Measure {
+	measure := units.Measure{
+		ServingUnit: f.ServingUnit,
+		ServingSize: f.ServingSize,
+		Density:     densityValue(f.Density),
+	}
+	for _, portion := range portions {
+		measure.Portions = append(measure.Portions, units.Portion{
+			Name:   portion.Name,
+			Amount: portion.Amount,
+			Unit:   portion.Unit,
+		})
+	}
+
+	return measure
+}
+
+func (s *FoodStore) GetPortions(ctx context.Context, foodID uuid.UUID) ([]FoodPortion, error) {
+	query := `
+		SELECT id, food_id, name, amount, unit, created_at
+		FROM food_portions
+		WHERE food_id = $1
+		ORDER BY name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, foodID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portions := []FoodPortion{}
+	for rows.Next() {
+		var portion FoodPortion
+		err := rows.Scan(
+			&portion.ID,
+			&portion.FoodID,
+			&portion.Name,
+			&portion.Amount,
+			&portion.Unit,
+			&portion.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		portions = append(portions, portion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return portions, nil
+}
+
+// CreatePortion adds a portion to a food, its name is stored normalised so
+// meal entries logged in it can find it.
+func (s *FoodStore) CreatePortion(ctx context.Context, portion *FoodPortion) error {
+	query := `
+		INSERT INTO food_portions (food_id, name, amount, unit)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	portion.Name = units.Normalize(portion.Name)
+	portion.Unit = units.Normalize(portion.Unit)
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		portion.FoodID,
+		portion.Name,
+		portion.Amount,
+		portion.Unit,
+	).Scan(
+		&portion.ID,
+		&portion.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505":
+				return ErrDuplicatePortion
+			case "23503":
+				return ErrNotFound
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeletePortion removes a portion no meal entry or recipe is measured in, they
+// would otherwise lose what their amount means.
+func (s *FoodStore) DeletePortion(ctx context.Context, foodID, portionID uuid.UUID) error {
+	query := `
+		DELETE FROM food_portions p
+		WHERE p.id = $1 AND p.food_id = $2
+		RETURNING EXISTS (
+			SELECT 1 FROM meal_entries e
+			WHERE e.food_id = p.food_id AND lower(trim(e.serving_unit)) = p.name
+		) OR EXISTS (
+			SELECT 1 FROM meal_template_entries e
+			WHERE e.food_id = p.food_id AND lower(trim(e.serving_unit)) = p.name
+		) OR EXISTS (
+			SELECT 1 FROM recipe_ingredients ri
+			WHERE ri.food_id = p.food_id AND ri.unit = p.name
+		)
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var inUse bool
+		err := tx.QueryRowContext(ctx, query, portionID, foodID).Scan(&inUse)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// rolls the delete back
+		if inUse {
+			return ErrPortionInUse
+		}
+
+		return nil
+	})
+}
+
+// loggedMeasure builds the measure of the version of a food an amount was
+// logged against, with the portion it was logged in if it was. Portions can't
+// be changed or removed while something is logged in them, so the current one
+// still holds.
+func loggedMeasure(servingUnit string, servingSize float64, density *float64, unit string, portionAmount *float64, portionUnit *string) units.Measure {
+	measure := units.Measure{
+		ServingUnit: servingUnit,
+		ServingSize: servingSize,
+		Density:     densityValue(density),
+	}
+	if portionAmount != nil && portionUnit != nil {
+		measure.Portions = []units.Portion{{Name: unit, Amount: *portionAmount, Unit: *portionUnit}}
+	}
+
+	return measure
+}
+
+func densityValue(density *float64) float64 {
+	if density == nil {
+		return 0
+	}
+
+	return *density
+}
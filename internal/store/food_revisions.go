@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// FoodRevision is a past version of a food, current from ValidFrom until an
+// edit by EditedBy replaced it at ReplacedAt.
+type FoodRevision struct {
+	ID             uuid.UUID      `json:"id"`
+	FoodID         uuid.UUID      `json:"food_id"`
+	Revision       int            `json:"revision"`
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	Brand          string         `json:"brand"`
+	Calories       int            `json:"calories"`
+	Protein        float64        `json:"protein"`
+	Carbs          float64        `json:"carbs"`
+	Fat            float64        `json:"fat"`
+	Fiber          float64        `json:"fiber"`
+	Micronutrients Micronutrients `json:"micronutrients"`
+	ServingSize    float64        `json:"serving_size"`
+	ServingUnit    string         `json:"serving_unit"`
+	Density        *float64       `json:"density"`
+	EditedBy       *uuid.UUID     `json:"edited_by"`
+	ValidFrom      string         `json:"valid_from"`
+	ReplacedAt     string         `json:"replaced_at"`
+}
+
+// foodRevisionJoin adds the version of the food an entry aliased e was logged
+// against as fv. It is null when the food hasn't changed since.
+const foodRevisionJoin = `
+	LEFT JOIN LATERAL (
+		SELECT fr.name, fr.brand, fr.serving_size, fr.serving_unit, fr.density,
+			fr.calories, fr.protein, fr.carbs, fr.fat, fr.fiber,
+			fr.sugar, fr.saturated_fat, fr.sodium, fr.cholesterol, fr.potassium, fr.vitamin_a, fr.vitamin_c, fr.vitamin_d
+		FROM food_revisions fr
+		WHERE fr.food_id = COALESCE(e.logged_food_id, e.food_id) AND fr.replaced_at > e.created_at
+		ORDER BY fr.replaced_at ASC
+		LIMIT 1
+	) fv ON true
+`
+
+// Update saves the food's details and status, keeping the version it replaces
+// so entries logged before the edit are still totalled against it.
+func (s *FoodStore) Update(ctx context.Context, food *Food, editorID uuid.UUID) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.saveRevision(ctx, tx, food.ID, &editorID); err != nil {
+			return err
+		}
+
+		return s.update(ctx, tx, food)
+	})
+}
+
+// saveRevision copies the food as it currently is into its revisions. The
+// editor is nil for changes made by imports.
+func (s *FoodStore) saveRevision(ctx context.Context, tx *sql.Tx, foodID uuid.UUID, editorID *uuid.UUID) error {
+	query := `
+		INSERT INTO food_revisions (food_id, revision, name, description, brand, calories, protein, carbs, fat, fiber,
+			sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, serving_size, serving_unit, density, edited_by, valid_from)
+		SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM food_revisions WHERE food_id = $1),
+			name, description, COALESCE(brand, ''), calories, protein, carbs, fat, fiber,
+			sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, serving_size, serving_unit, density,
+			$2, COALESCE(updated_at, created_at)
+		FROM foods
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, foodID, editorID)
+	if err != nil {
+		// another edit saved the same revision number first
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *FoodStore) update(ctx context.Context, tx *sql.Tx, food *Food) error {
+	query := `
+		UPDATE foods
+		SET name = $1, description = $2, calories = $3, protein = $4, carbs = $5, fat = $6,
+			fiber = $7, sugar = $8, saturated_fat = $9, sodium = $10, cholesterol = $11, potassium = $12,
+			vitamin_a = $13, vitamin_c = $14, vitamin_d = $15, density = $16, brand = $17, serving_size = $18,
+			serving_unit = $19, barcode = $20, status = $21, verified = $22, rejection_reason = $23,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $24 RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		food.Name,
+		food.Description,
+		food.Calories,
+		food.Protein,
+		food.Carbs,
+		food.Fat,
+		food.Fiber,
+		food.Micronutrients.Sugar,
+		food.Micronutrients.SaturatedFat,
+		food.Micronutrients.Sodium,
+		food.Micronutrients.Cholesterol,
+		food.Micronutrients.Potassium,
+		food.Micronutrients.VitaminA,
+		food.Micronutrients.VitaminC,
+		food.Micronutrients.VitaminD,
+		food.Density,
+		food.Brand,
+		food.ServingSize,
+		food.ServingUnit,
+		food.Barcode,
+		food.Status,
+		food.Verified,
+		food.RejectionReason,
+		food.ID,
+	).Scan(
+		&food.UpdatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateBarcode
+		}
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRevisions lists the past versions of a food, most recent first.
+func (s *FoodStore) GetRevisions(ctx context.Context, foodID uuid.UUID) ([]FoodRevision, error) {
+	query := `
+		SELECT id, food_id, revision, name, description, brand, calories, protein, carbs, fat, fiber,
+			sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d,
+			serving_size, serving_unit, density, edited_by, valid_from, replaced_at
+		FROM food_revisions
+		WHERE food_id = $1
+		ORDER BY revision DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, foodID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []FoodRevision{}
+	for rows.Next() {
+		var revision FoodRevision
+		err := rows.Scan(
+			&revision.ID,
+			&revision.FoodID,
+			&revision.Revision,
+			&revision.Name,
+			&revision.Description,
+			&revision.Brand,
+			&revision.Calories,
+			&revision.Protein,
+			&revision.Carbs,
+			&revision.Fat,
+			&revision.Fiber,
+			&revision.Micronutrients.Sugar,
+			&revision.Micronutrients.SaturatedFat,
+			&revision.Micronutrients.Sodium,
+			&revision.Micronutrients.Cholesterol,
+			&revision.Micronutrients.Potassium,
+			&revision.Micronutrients.VitaminA,
+			&revision.Micronutrients.VitaminC,
+			&revision.Micronutrients.VitaminD,
+			&revision.ServingSize,
+			&revision.ServingUnit,
+			&revision.Density,
+			&revision.EditedBy,
+			&revision.ValidFrom,
+			&revision.ReplacedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
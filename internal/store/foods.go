@@ -4,8 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type FoodVisibility string
+
+const (
+	FoodPrivate FoodVisibility = "private"
+	FoodPublic  FoodVisibility = "public"
+)
+
+type FoodStatus string
+
+const (
+	FoodPending  FoodStatus = "pending"
+	FoodApproved FoodStatus = "approved"
+	FoodRejected FoodStatus = "rejected"
+	// FoodMerged foods were folded into another food, they are only kept for
+	// the entries logged against them.
+	FoodMerged FoodStatus = "merged"
 )
 
 type Food struct {
@@ -16,13 +36,73 @@ type Food struct {
 	Protein     float64   `json:"protein"`
 	Carbs       float64   `json:"carbs"`
 	Fat         float64   `json:"fat"`
+	Fiber       float64   `json:"fiber"`
 	Brand       string    `json:"brand"`
 	ServingSize float64   `json:"serving_size"`
 	ServingUnit string    `json:"serving_unit"`
-	Verified    bool      `json:"verified"`
-	UserID      uuid.UUID `json:"user_id"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	// Micronutrients are per serving like the macros.
+	Micronutrients Micronutrients `json:"micronutrients"`
+	// Density is in g/ml, nil when amounts can't be converted between mass and volume.
+	Density *float64 `json:"density"`
+	// Barcode is a normalised EAN-13, see NormalizeBarcode.
+	Barcode    *string        `json:"barcode"`
+	Verified   bool           `json:"verified"`
+	Visibility FoodVisibility `json:"visibility"`
+	// Status tracks moderation, public foods only show up for other users once
+	// they are approved.
+	Status          FoodStatus `json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	// UserID is who added the food, nil for foods that predate it being recorded.
+	UserID    *uuid.UUID `json:"user_id"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+}
+
+// Micronutrients is the label detail beyond the macros. Sugar and saturated
+// fat are in grams, vitamins A and D in micrograms and the rest in milligrams.
+type Micronutrients struct {
+	Sugar        float64 `json:"sugar"`
+	SaturatedFat float64 `json:"saturated_fat"`
+	Sodium       float64 `json:"sodium"`
+	Cholesterol  float64 `json:"cholesterol"`
+	Potassium    float64 `json:"potassium"`
+	VitaminA     float64 `json:"vitamin_a"`
+	VitaminC     float64 `json:"vitamin_c"`
+	VitaminD     float64 `json:"vitamin_d"`
+}
+
+func (m *Micronutrients) add(other Micronutrients) {
+	m.Sugar += other.Sugar
+	m.SaturatedFat += other.SaturatedFat
+	m.Sodium += other.Sodium
+	m.Cholesterol += other.Cholesterol
+	m.Potassium += other.Potassium
+	m.VitaminA += other.VitaminA
+	m.VitaminC += other.VitaminC
+	m.VitaminD += other.VitaminD
+}
+
+func (m Micronutrients) scale(factor float64) Micronutrients {
+	return Micronutrients{
+		Sugar:        m.Sugar * factor,
+		SaturatedFat: m.SaturatedFat * factor,
+		Sodium:       m.Sodium * factor,
+		Cholesterol:  m.Cholesterol * factor,
+		Potassium:    m.Potassium * factor,
+		VitaminA:     m.VitaminA * factor,
+		VitaminC:     m.VitaminC * factor,
+		VitaminD:     m.VitaminD * factor,
+	}
+}
+
+// VisibleTo reports whether the user may see the food. Private foods, and
+// public ones still waiting for moderation, are only shown to whoever added them.
+func (f *Food) VisibleTo(userID uuid.UUID) bool {
+	if f.UserID != nil && *f.UserID == userID {
+		return true
+	}
+
+	return f.Visibility == FoodPublic && f.Status == FoodApproved
 }
 
 type FoodStore struct {
@@ -31,8 +111,9 @@ type FoodStore struct {
 
 func (s *FoodStore) Create(ctx context.Context, food *Food) error {
 	query := `
-		INSERT INTO foods (name, description, calories, protein, carbs, fat, brand, serving_size, serving_unit)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at
+		INSERT INTO foods (name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d,
+			density, brand, serving_size, serving_unit, barcode, visibility, user_id, status, verified)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24) RETURNING id, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -47,14 +128,33 @@ func (s *FoodStore) Create(ctx context.Context, food *Food) error {
 		food.Protein,
 		food.Carbs,
 		food.Fat,
+		food.Fiber,
+		food.Micronutrients.Sugar,
+		food.Micronutrients.SaturatedFat,
+		food.Micronutrients.Sodium,
+		food.Micronutrients.Cholesterol,
+		food.Micronutrients.Potassium,
+		food.Micronutrients.VitaminA,
+		food.Micronutrients.VitaminC,
+		food.Micronutrients.VitaminD,
+		food.Density,
 		food.Brand,
 		food.ServingSize,
 		food.ServingUnit,
+		food.Barcode,
+		food.Visibility,
+		food.UserID,
+		food.Status,
+		food.Verified,
 	).Scan(
 		&food.ID,
 		&food.CreatedAt,
 	)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateBarcode
+		}
+
 		return err
 	}
 
@@ -63,9 +163,9 @@ func (s *FoodStore) Create(ctx context.Context, food *Food) error {
 
 func (s *FoodStore) GetByID(ctx context.Context, id uuid.UUID) (*Food, error) {
 	query := `
-		SELECT id, name, description, calories, protein, carbs, fat, brand, serving_size, serving_unit, verified, user_id, created_at, updated_at
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at
 		FROM foods
-		WHERE id = $1
+		WHERE id = COALESCE((SELECT food_id FROM food_redirects WHERE from_id = $1), $1)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -80,10 +180,24 @@ func (s *FoodStore) GetByID(ctx context.Context, id uuid.UUID) (*Food, error) {
 		&food.Protein,
 		&food.Carbs,
 		&food.Fat,
+		&food.Fiber,
+		&food.Micronutrients.Sugar,
+		&food.Micronutrients.SaturatedFat,
+		&food.Micronutrients.Sodium,
+		&food.Micronutrients.Cholesterol,
+		&food.Micronutrients.Potassium,
+		&food.Micronutrients.VitaminA,
+		&food.Micronutrients.VitaminC,
+		&food.Micronutrients.VitaminD,
+		&food.Density,
 		&food.Brand,
 		&food.ServingSize,
 		&food.ServingUnit,
+		&food.Barcode,
 		&food.Verified,
+		&food.Visibility,
+		&food.Status,
+		&food.RejectionReason,
 		&food.UserID,
 		&food.CreatedAt,
 		&food.UpdatedAt,
@@ -99,3 +213,314 @@ func (s *FoodStore) GetByID(ctx context.Context, id uuid.UUID) (*Food, error) {
 
 	return &food, nil
 }
+
+type FoodPage struct {
+	Foods      []Food `json:"foods"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type foodCursor struct {
+	Score string    `json:"s"`
+	Name  string    `json:"n"`
+	ID    uuid.UUID `json:"i"`
+}
+
+// foodSearchFilter matches on the english tsvector (served by idx_foods_name)
+// and falls back to trigram similarity so misspelt searches still hit.
+const foodSearchFilter = `
+	WHERE ($1 = '' OR to_tsvector('english', name) @@ plainto_tsquery('english', $1) OR name % $1)
+		AND ($2 = '' OR lower(brand) = lower($2))
+		AND ($3::boolean IS NULL OR verified = $3)
+		AND ($4::numeric IS NULL OR calories >= $4)
+		AND ($5::numeric IS NULL OR calories <= $5)
+		AND ($6::numeric IS NULL OR protein >= $6)
+		AND ($7::numeric IS NULL OR protein <= $7)
+		AND ($8::numeric IS NULL OR carbs >= $8)
+		AND ($9::numeric IS NULL OR carbs <= $9)
+		AND ($10::numeric IS NULL OR fat >= $10)
+		AND ($11::numeric IS NULL OR fat <= $11)
+		AND ((visibility = 'public' AND status = 'approved') OR (user_id = $12 AND status <> 'merged'))
+`
+
+func (s *FoodStore) Search(ctx context.Context, fq PaginatedFoodQuery) (*FoodPage, error) {
+	var cursor foodCursor
+	var cursorScore *string
+	if fq.Cursor != "" {
+		if err := decodeCursor(fq.Cursor, &cursor); err != nil {
+			return nil, err
+		}
+
+		cursorScore = &cursor.Score
+	}
+
+	args := []any{
+		fq.Search,
+		fq.Brand,
+		fq.Verified,
+		fq.Calories.Min,
+		fq.Calories.Max,
+		fq.Protein.Min,
+		fq.Protein.Max,
+		fq.Carbs.Min,
+		fq.Carbs.Max,
+		fq.Fat.Min,
+		fq.Fat.Max,
+		fq.Viewer,
+	}
+
+	countQuery := `SELECT COUNT(*) FROM foods` + foodSearchFilter
+
+	query := `
+		WITH matches AS (
+			SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, '') AS brand, serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at,
+				CASE WHEN $1 = '' THEN 0
+				ELSE ROUND((ts_rank(to_tsvector('english', name), plainto_tsquery('english', $1)) + similarity(name, $1))::numeric, 6)
+				END
+				-- a small boost so verified foods outrank unverified ones of similar
+				-- relevance without burying better matches
+				+ CASE WHEN verified THEN 0.1 ELSE 0 END AS score
+			FROM foods` + foodSearchFilter + `
+		)
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, brand, serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at, score
+		FROM matches
+		WHERE $13::numeric IS NULL OR score < $13 OR (score = $13 AND (name, id) > ($14, $15))
+		ORDER BY score DESC, name ASC, id ASC
+		LIMIT $16
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &FoodPage{Foods: []Food{}}
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	// fetch one extra row to know whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, append(args, cursorScore, cursor.Name, cursor.ID, fq.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last foodCursor
+	for rows.Next() {
+		var food Food
+		var score string
+		err := rows.Scan(
+			&food.ID,
+			&food.Name,
+			&food.Description,
+			&food.Calories,
+			&food.Protein,
+			&food.Carbs,
+			&food.Fat,
+			&food.Fiber,
+			&food.Micronutrients.Sugar,
+			&food.Micronutrients.SaturatedFat,
+			&food.Micronutrients.Sodium,
+			&food.Micronutrients.Cholesterol,
+			&food.Micronutrients.Potassium,
+			&food.Micronutrients.VitaminA,
+			&food.Micronutrients.VitaminC,
+			&food.Micronutrients.VitaminD,
+			&food.Density,
+			&food.Brand,
+			&food.ServingSize,
+			&food.ServingUnit,
+			&food.Barcode,
+			&food.Verified,
+			&food.Visibility,
+			&food.Status,
+			&food.RejectionReason,
+			&food.UserID,
+			&food.CreatedAt,
+			&food.UpdatedAt,
+			&score,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Foods) == fq.Limit {
+			next, err := encodeCursor(last)
+			if err != nil {
+				return nil, err
+			}
+
+			page.NextCursor = next
+			break
+		}
+
+		page.Foods = append(page.Foods, food)
+		last = foodCursor{Score: score, Name: food.Name, ID: food.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+type foodListCursor struct {
+	At time.Time `json:"a"`
+	ID uuid.UUID `json:"i"`
+}
+
+// GetByUser lists the foods the user added, newest first.
+func (s *FoodStore) GetByUser(ctx context.Context, userID uuid.UUID, fq PaginatedQuery) (*FoodPage, error) {
+	query := `
+		SELECT id, name, description, calories, protein, carbs, fat, fiber, sugar, saturated_fat, sodium, cholesterol, potassium, vitamin_a, vitamin_c, vitamin_d, density, COALESCE(brand, ''), serving_size, serving_unit, barcode, verified, visibility, status, rejection_reason, user_id, created_at, updated_at,
+			created_at
+		FROM foods
+		WHERE user_id = $1 AND status <> 'merged'
+			AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+
+	countQuery := `SELECT COUNT(*) FROM foods WHERE user_id = $1 AND status <> 'merged'`
+
+	return s.listFoods(ctx, query, countQuery, []any{userID}, fq)
+}
+
+// GetFavourites lists the foods the user favourited, most recent first. Foods
+// the user can no longer see are left out.
+func (s *FoodStore) GetFavourites(ctx context.Context, userID uuid.UUID, fq PaginatedQuery) (*FoodPage, error) {
+	query := `
+		SELECT f.id, f.name, f.description, f.calories, f.protein, f.carbs, f.fat, f.fiber, f.sugar, f.saturated_fat, f.sodium, f.cholesterol, f.potassium, f.vitamin_a, f.vitamin_c, f.vitamin_d, f.density, COALESCE(f.brand, ''), f.serving_size, f.serving_unit, f.barcode, f.verified, f.visibility, f.status, f.rejection_reason, f.user_id, f.created_at, f.updated_at,
+			ff.created_at
+		FROM favourite_foods ff
+		JOIN foods f ON f.id = ff.food_id
+		WHERE ff.user_id = $1
+			AND ((f.visibility = 'public' AND f.status = 'approved') OR (f.user_id = $1 AND f.status <> 'merged'))
+			AND ($2::timestamptz IS NULL OR (ff.created_at, f.id) < ($2, $3))
+		ORDER BY ff.created_at DESC, f.id DESC
+		LIMIT $4
+	`
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM favourite_foods ff
+		JOIN foods f ON f.id = ff.food_id
+		WHERE ff.user_id = $1 AND ((f.visibility = 'public' AND f.status = 'approved') OR (f.user_id = $1 AND f.status <> 'merged'))
+	`
+
+	return s.listFoods(ctx, query, countQuery, []any{userID}, fq)
+}
+
+// listFoods runs a query for a page of foods whose last column is the
+// timestamp they are sorted by. The cursor and limit are passed after args.
+func (s *FoodStore) listFoods(ctx context.Context, query, countQuery string, args []any, fq PaginatedQuery) (*FoodPage, error) {
+	var cursor foodListCursor
+	var cursorAt *time.Time
+	if fq.Cursor != "" {
+		if err := decodeCursor(fq.Cursor, &cursor); err != nil {
+			return nil, err
+		}
+
+		cursorAt = &cursor.At
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &FoodPage{Foods: []Food{}}
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	// fetch one extra row to know whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, append(args, cursorAt, cursor.ID, fq.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last foodListCursor
+	for rows.Next() {
+		var food Food
+		var at time.Time
+		err := rows.Scan(
+			&food.ID,
+			&food.Name,
+			&food.Description,
+			&food.Calories,
+			&food.Protein,
+			&food.Carbs,
+			&food.Fat,
+			&food.Fiber,
+			&food.Micronutrients.Sugar,
+			&food.Micronutrients.SaturatedFat,
+			&food.Micronutrients.Sodium,
+			&food.Micronutrients.Cholesterol,
+			&food.Micronutrients.Potassium,
+			&food.Micronutrients.VitaminA,
+			&food.Micronutrients.VitaminC,
+			&food.Micronutrients.VitaminD,
+			&food.Density,
+			&food.Brand,
+			&food.ServingSize,
+			&food.ServingUnit,
+			&food.Barcode,
+			&food.Verified,
+			&food.Visibility,
+			&food.Status,
+			&food.RejectionReason,
+			&food.UserID,
+			&food.CreatedAt,
+			&food.UpdatedAt,
+			&at,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Foods) == fq.Limit {
+			next, err := encodeCursor(last)
+			if err != nil {
+				return nil, err
+			}
+
+			page.NextCursor = next
+			break
+		}
+
+		page.Foods = append(page.Foods, food)
+		last = foodListCursor{At: at, ID: food.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (s *FoodStore) Favourite(ctx context.Context, userID, foodID uuid.UUID) error {
+	query := `
+		INSERT INTO favourite_foods (user_id, food_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, food_id) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, foodID)
+	return err
+}
+
+func (s *FoodStore) Unfavourite(ctx context.Context, userID, foodID uuid.UUID) error {
+	query := `
+		DELETE FROM favourite_foods
+		WHERE user_id = $1 AND food_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, foodID)
+	return err
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// MealTemplate is a named set of foods and recipes that can be logged to a
+// meal in one go.
+type MealTemplate struct {
+	ID        uuid.UUID           `json:"id"`
+	UserID    uuid.UUID           `json:"user_id"`
+	Name      string              `json:"name"`
+	MealName  string              `json:"meal_name"`
+	CreatedAt string              `json:"created_at"`
+	UpdatedAt string              `json:"updated_at"`
+	Entries   []MealTemplateEntry `json:"entries,omitempty"`
+}
+
+type MealTemplateEntry struct {
+	ID          uuid.UUID  `json:"id"`
+	TemplateID  uuid.UUID  `json:"template_id"`
+	FoodID      *uuid.UUID `json:"food_id"`
+	RecipeID    *uuid.UUID `json:"recipe_id"`
+	Position    int        `json:"position"`
+	ServingUnit string     `json:"serving_unit"`
+	// LegacyUnit is what an amount saved before units were checked was written
+	// as, when it couldn't be converted to ServingUnit.
+	LegacyUnit string  `json:"legacy_unit,omitempty"`
+	Amount     float64 `json:"amount"`
+}
+
+type MealTemplateStore struct {
+	db *sql.DB
+}
+
+func (s *MealTemplateStore) Create(ctx context.Context, template *MealTemplate) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, template); err != nil {
+			return err
+		}
+
+		return s.createEntries(ctx, tx, template)
+	})
+}
+
+func (s *MealTemplateStore) create(ctx context.Context, tx *sql.Tx, template *MealTemplate) error {
+	query := `
+		INSERT INTO meal_templates (user_id, name, meal_name)
+		VALUES ($1, $2, $3) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		template.UserID,
+		template.Name,
+		template.MealName,
+	).Scan(
+		&template.ID,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *MealTemplateStore) createEntries(ctx context.Context, tx *sql.Tx, template *MealTemplate) error {
+	query := `
+		INSERT INTO meal_template_entries (template_id, food_id, recipe_id, position, serving_unit, amount)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		entry.TemplateID = template.ID
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			entry.TemplateID,
+			entry.FoodID,
+			entry.RecipeID,
+			entry.Position,
+			entry.ServingUnit,
+			entry.Amount,
+		).Scan(
+			&entry.ID,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				if entry.RecipeID != nil {
+					return ErrUnknownRecipe
+				}
+
+				return ErrUnknownFood
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MealTemplateStore) GetByID(ctx context.Context, id uuid.UUID) (*MealTemplate, error) {
+	query := `
+		SELECT id, user_id, name, meal_name, created_at, updated_at
+		FROM meal_templates
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var template MealTemplate
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&template.ID,
+		&template.UserID,
+		&template.Name,
+		&template.MealName,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	entries, err := s.getEntries(ctx, template.ID)
+	if err != nil {
+		return nil, err
+	}
+	template.Entries = entries
+
+	return &template, nil
+}
+
+func (s *MealTemplateStore) getEntries(ctx context.Context, templateID uuid.UUID) ([]MealTemplateEntry, error) {
+	query := `
+		SELECT id, template_id, food_id, recipe_id, position, serving_unit, COALESCE(legacy_unit, ''), amount
+		FROM meal_template_entries
+		WHERE template_id = $1
+		ORDER BY position ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []MealTemplateEntry{}
+	for rows.Next() {
+		var entry MealTemplateEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.TemplateID,
+			&entry.FoodID,
+			&entry.RecipeID,
+			&entry.Position,
+			&entry.ServingUnit,
+			&entry.LegacyUnit,
+			&entry.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *MealTemplateStore) GetByUser(ctx context.Context, userID uuid.UUID) ([]MealTemplate, error) {
+	query := `
+		SELECT id, user_id, name, meal_name, created_at, updated_at
+		FROM meal_templates
+		WHERE user_id = $1
+		ORDER BY name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []MealTemplate{}
+	for rows.Next() {
+		var template MealTemplate
+		err := rows.Scan(
+			&template.ID,
+			&template.UserID,
+			&template.Name,
+			&template.MealName,
+			&template.CreatedAt,
+			&template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (s *MealTemplateStore) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM meal_templates
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Apply logs every entry of the template to the given meal, creating the meal
+// if needed. Either all entries are logged or none are.
+func (s *MealTemplateStore) Apply(ctx context.Context, template *MealTemplate, meal *Meal, consumedAt string) ([]MealEntry, error) {
+	meals := &MealStore{s.db}
+
+	entries := []MealEntry{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := meals.upsertMeal(ctx, tx, meal); err != nil {
+			return err
+		}
+
+		for _, templateEntry := range template.Entries {
+			entry := MealEntry{
+				MealID:      meal.ID,
+				FoodID:      templateEntry.FoodID,
+				RecipeID:    templateEntry.RecipeID,
+				ServingUnit: templateEntry.ServingUnit,
+				LegacyUnit:  templateEntry.LegacyUnit,
+				Amount:      templateEntry.Amount,
+				ConsumedAt:  consumedAt,
+			}
+			if err := meals.createMealEntry(ctx, tx, &entry); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
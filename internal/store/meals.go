@@ -3,8 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Meal struct {
@@ -12,19 +14,31 @@ type Meal struct {
 	UserID    uuid.UUID `json:"user_id"`
 	Name      string    `json:"name"`
 	Date      string    `json:"date"`
+	Shared    bool      `json:"shared"`
 	CreatedAt string    `json:"created_at"`
 	UpdatedAt string    `json:"updated_at"`
 }
 
 type MealEntry struct {
-	ID          uuid.UUID `json:"id"`
-	MealID      uuid.UUID `json:"meal_id"`
-	FoodID      uuid.UUID `json:"food_id"`
-	ServingUnit string    `json:"serving_unit"`
-	Amount      float64   `json:"amount"`
-	ConsumedAt  string    `json:"consumed_at"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	ID          uuid.UUID  `json:"id"`
+	MealID      uuid.UUID  `json:"meal_id"`
+	FoodID      *uuid.UUID `json:"food_id"`
+	RecipeID    *uuid.UUID `json:"recipe_id"`
+	ServingUnit string     `json:"serving_unit"`
+	// LegacyUnit is what an amount saved before units were checked was written
+	// as, when it couldn't be converted to ServingUnit.
+	LegacyUnit string  `json:"legacy_unit,omitempty"`
+	Amount     float64 `json:"amount"`
+	ConsumedAt string  `json:"consumed_at"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+// MealEntryWithMeal is a meal entry along with the meal it was logged in, which
+// is what records who the entry belongs to.
+type MealEntryWithMeal struct {
+	MealEntry
+	Meal Meal `json:"meal"`
 }
 
 type MealStore struct {
@@ -59,7 +73,7 @@ func (s *MealStore) CreateMeal(ctx context.Context, meal *Meal) error {
 
 func (s *MealStore) GetMeal(ctx context.Context, meal Meal) (*Meal, error) {
 	query := `
-		SELECT id, user_id, name, date, created_at, updated_at
+		SELECT id, user_id, name, date, shared, created_at, updated_at
 		FROM meals
 		WHERE user_id = $1 AND name = $2 AND date = $3
 	`
@@ -78,6 +92,7 @@ func (s *MealStore) GetMeal(ctx context.Context, meal Meal) (*Meal, error) {
 		&meal.UserID,
 		&meal.Name,
 		&meal.Date,
+		&meal.Shared,
 		&meal.CreatedAt,
 		&meal.UpdatedAt,
 	)
@@ -95,7 +110,7 @@ func (s *MealStore) GetMeal(ctx context.Context, meal Meal) (*Meal, error) {
 
 func (s *MealStore) GetMealByID(ctx context.Context, id uuid.UUID) (*Meal, error) {
 	query := `
-		SELECT id, user_id, name, date, created_at, updated_at
+		SELECT id, user_id, name, date, shared, created_at, updated_at
 		FROM meals
 		WHERE id = $1
 	`
@@ -109,6 +124,7 @@ func (s *MealStore) GetMealByID(ctx context.Context, id uuid.UUID) (*Meal, error
 		&meal.UserID,
 		&meal.Name,
 		&meal.Date,
+		&meal.Shared,
 		&meal.CreatedAt,
 		&meal.UpdatedAt,
 	)
@@ -124,21 +140,47 @@ func (s *MealStore) GetMealByID(ctx context.Context, id uuid.UUID) (*Meal, error
 	return &meal, nil
 }
 
+func (s *MealStore) SetMealShared(ctx context.Context, mealID uuid.UUID, shared bool) error {
+	query := `
+		UPDATE meals
+		SET shared = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, shared, mealID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *MealStore) CreateMealEntry(ctx context.Context, entry *MealEntry) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.createMealEntry(ctx, tx, entry)
+	})
+}
+
+func (s *MealStore) createMealEntry(ctx context.Context, tx *sql.Tx, entry *MealEntry) error {
 	query := `
-		INSERT INTO meal_entries (meal_id, food_id, serving_unit, amount, consumed_at)
-		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+		INSERT INTO meal_entries (meal_id, food_id, recipe_id, serving_unit, legacy_unit, amount, consumed_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7) RETURNING id, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		entry.MealID,
 		entry.FoodID,
+		entry.RecipeID,
 		entry.ServingUnit,
+		entry.LegacyUnit,
 		entry.Amount,
 		entry.ConsumedAt,
 	).Scan(
@@ -146,6 +188,14 @@ func (s *MealStore) CreateMealEntry(ctx context.Context, entry *MealEntry) error
 		&entry.CreatedAt,
 	)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			if entry.RecipeID != nil {
+				return ErrUnknownRecipe
+			}
+
+			return ErrUnknownFood
+		}
+
 		return err
 	}
 
@@ -154,7 +204,7 @@ func (s *MealStore) CreateMealEntry(ctx context.Context, entry *MealEntry) error
 
 func (s *MealStore) GetMealEntryByID(ctx context.Context, id uuid.UUID) (*MealEntry, error) {
 	query := `
-		SELECT id, meal_id, food_id, serving_unit, amount, consumed_at, created_at, updated_at
+		SELECT id, meal_id, food_id, recipe_id, serving_unit, COALESCE(legacy_unit, ''), amount, consumed_at, created_at, updated_at
 		FROM meal_entries
 		WHERE id = $1
 	`
@@ -167,7 +217,9 @@ func (s *MealStore) GetMealEntryByID(ctx context.Context, id uuid.UUID) (*MealEn
 		&entry.ID,
 		&entry.MealID,
 		&entry.FoodID,
+		&entry.RecipeID,
 		&entry.ServingUnit,
+		&entry.LegacyUnit,
 		&entry.Amount,
 		&entry.ConsumedAt,
 		&entry.CreatedAt,
@@ -185,10 +237,54 @@ func (s *MealStore) GetMealEntryByID(ctx context.Context, id uuid.UUID) (*MealEn
 	return &entry, nil
 }
 
+func (s *MealStore) GetMealEntryWithMeal(ctx context.Context, id uuid.UUID) (*MealEntryWithMeal, error) {
+	query := `
+		SELECT me.id, me.meal_id, me.food_id, me.recipe_id, me.serving_unit, COALESCE(me.legacy_unit, ''), me.amount, me.consumed_at, me.created_at, me.updated_at,
+			m.id, m.user_id, m.name, m.date, m.shared, m.created_at, m.updated_at
+		FROM meal_entries me
+		JOIN meals m ON m.id = me.meal_id
+		WHERE me.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var entry MealEntryWithMeal
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&entry.ID,
+		&entry.MealID,
+		&entry.FoodID,
+		&entry.RecipeID,
+		&entry.ServingUnit,
+		&entry.LegacyUnit,
+		&entry.Amount,
+		&entry.ConsumedAt,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+		&entry.Meal.ID,
+		&entry.Meal.UserID,
+		&entry.Meal.Name,
+		&entry.Meal.Date,
+		&entry.Meal.Shared,
+		&entry.Meal.CreatedAt,
+		&entry.Meal.UpdatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
 func (s *MealStore) UpdateMealEntry(ctx context.Context, entry *MealEntry) error {
 	query := `
 		UPDATE meal_entries
-		SET meal_id = $1, serving_unit = $2, amount = $3, consumed_at = $4, updated_at = CURRENT_TIMESTAMP
+		SET meal_id = $1, serving_unit = $2, legacy_unit = NULL, amount = $3, consumed_at = $4, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $5 RETURNING id, created_at, updated_at
 	`
 
@@ -231,3 +327,631 @@ func (s *MealStore) DeleteMealEntry(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+func (s *MealStore) GetMealEntries(ctx context.Context, mealID uuid.UUID) ([]MealEntry, error) {
+	query := `
+		SELECT id, meal_id, food_id, recipe_id, serving_unit, COALESCE(legacy_unit, ''), amount, consumed_at, created_at, updated_at
+		FROM meal_entries
+		WHERE meal_id = $1
+		ORDER BY consumed_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, mealID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []MealEntry{}
+	for rows.Next() {
+		var entry MealEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.MealID,
+			&entry.FoodID,
+			&entry.RecipeID,
+			&entry.ServingUnit,
+			&entry.LegacyUnit,
+			&entry.Amount,
+			&entry.ConsumedAt,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// CopyMeals logs the entries of the user's meals on one date again on another,
+// keeping the time of day they were eaten at. Only mealName is copied when it
+// is set, into toMealName if that is set too. Nothing is copied unless every
+// meal is.
+func (s *MealStore) CopyMeals(ctx context.Context, userID uuid.UUID, from, to time.Time, mealName, toMealName string) ([]MealEntry, error) {
+	query := `
+		SELECT id, name
+		FROM meals
+		WHERE user_id = $1 AND date = $2 AND ($3 = '' OR name::text = $3)
+	`
+
+	copied := []MealEntry{}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, query, userID, from.Format(time.DateOnly), mealName)
+		if err != nil {
+			return err
+		}
+
+		var sources []Meal
+		for rows.Next() {
+			var meal Meal
+			if err := rows.Scan(&meal.ID, &meal.Name); err != nil {
+				rows.Close()
+				return err
+			}
+
+			sources = append(sources, meal)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(sources) == 0 {
+			return ErrNotFound
+		}
+
+		offset := int(to.Sub(from).Hours() / 24)
+		for _, source := range sources {
+			target := Meal{UserID: userID, Name: source.Name, Date: to.Format(time.DateOnly)}
+			if toMealName != "" {
+				target.Name = toMealName
+			}
+
+			if err := s.upsertMeal(ctx, tx, &target); err != nil {
+				return err
+			}
+
+			entries, err := s.copyEntries(ctx, tx, source.ID, target.ID, offset)
+			if err != nil {
+				return err
+			}
+
+			copied = append(copied, entries...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
+// upsertMeal fills in the ID of the user's meal of that name and date, creating
+// the meal if nothing has been logged to it yet.
+func (s *MealStore) upsertMeal(ctx context.Context, tx *sql.Tx, meal *Meal) error {
+	query := `
+		INSERT INTO meals (user_id, name, date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name, date) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+		RETURNING id, shared, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		meal.UserID,
+		meal.Name,
+		meal.Date,
+	).Scan(
+		&meal.ID,
+		&meal.Shared,
+		&meal.CreatedAt,
+		&meal.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyEntries duplicates the entries of one meal into another, moving their
+// consumed_at by offsetDays.
+func (s *MealStore) copyEntries(ctx context.Context, tx *sql.Tx, fromMealID, toMealID uuid.UUID, offsetDays int) ([]MealEntry, error) {
+	query := `
+		INSERT INTO meal_entries (meal_id, food_id, recipe_id, serving_unit, legacy_unit, amount, consumed_at)
+		SELECT $2, food_id, recipe_id, serving_unit, legacy_unit, amount, consumed_at + make_interval(days => $3)
+		FROM meal_entries
+		WHERE meal_id = $1
+		ORDER BY consumed_at, created_at
+		RETURNING id, meal_id, food_id, recipe_id, serving_unit, COALESCE(legacy_unit, ''), amount, consumed_at, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, fromMealID, toMealID, offsetDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []MealEntry
+	for rows.Next() {
+		var entry MealEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.MealID,
+			&entry.FoodID,
+			&entry.RecipeID,
+			&entry.ServingUnit,
+			&entry.LegacyUnit,
+			&entry.Amount,
+			&entry.ConsumedAt,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// TimesOfDay maps each time of day to the hours, in the user's time zone, it
+// covers. Night wraps around midnight.
+var TimesOfDay = map[string][2]int{
+	"morning":   {5, 11},
+	"afternoon": {11, 17},
+	"evening":   {17, 22},
+	"night":     {22, 5},
+}
+
+// suggestionWindow is how far back logged foods count towards suggestions.
+const suggestionWindow = 90 * 24 * time.Hour
+
+const suggestionLimit = 20
+
+// LoggedFood is a food the user has logged, with the serving they used last.
+type LoggedFood struct {
+	FoodID       uuid.UUID `json:"food_id"`
+	Name         string    `json:"name"`
+	Brand        string    `json:"brand"`
+	ServingUnit  string    `json:"serving_unit"`
+	Amount       float64   `json:"amount"`
+	LastLoggedAt string    `json:"last_logged_at"`
+	TimesLogged  int       `json:"times_logged"`
+}
+
+type FoodSuggestions struct {
+	Recent   []LoggedFood `json:"recent"`
+	Frequent []LoggedFood `json:"frequent"`
+}
+
+// GetFoodSuggestions returns the foods the user logged most recently and the
+// ones they log most often in the queried meal and time of day.
+func (s *MealStore) GetFoodSuggestions(ctx context.Context, userID uuid.UUID, q FoodSuggestionQuery) (*FoodSuggestions, error) {
+	recent, err := s.getLoggedFoods(ctx, userID, FoodSuggestionQuery{Location: q.Location}, "l.consumed_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	frequent, err := s.getLoggedFoods(ctx, userID, q, "c.times DESC, l.consumed_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	return &FoodSuggestions{Recent: recent, Frequent: frequent}, nil
+}
+
+func (s *MealStore) getLoggedFoods(ctx context.Context, userID uuid.UUID, q FoodSuggestionQuery, orderBy string) ([]LoggedFood, error) {
+	query := `
+		WITH logged AS (
+			SELECT e.food_id, e.serving_unit, e.amount, e.consumed_at
+			FROM meal_entries e
+			JOIN meals m ON m.id = e.meal_id
+			WHERE m.user_id = $1
+				AND e.food_id IS NOT NULL
+				AND e.consumed_at > $2
+				AND ($3 = '' OR m.name::text = $3)
+				AND ($4::int IS NULL OR CASE
+					WHEN $4 < $5 THEN EXTRACT(HOUR FROM e.consumed_at AT TIME ZONE $6) >= $4
+						AND EXTRACT(HOUR FROM e.consumed_at AT TIME ZONE $6) < $5
+					ELSE EXTRACT(HOUR FROM e.consumed_at AT TIME ZONE $6) >= $4
+						OR EXTRACT(HOUR FROM e.consumed_at AT TIME ZONE $6) < $5
+				END)
+		),
+		latest AS (
+			SELECT DISTINCT ON (food_id) food_id, serving_unit, amount, consumed_at
+			FROM logged
+			ORDER BY food_id, consumed_at DESC
+		),
+		counts AS (
+			SELECT food_id, COUNT(*) AS times
+			FROM logged
+			GROUP BY food_id
+		)
+		SELECT f.id, f.name, COALESCE(f.brand, ''), l.serving_unit, l.amount, l.consumed_at, c.times
+		FROM latest l
+		JOIN counts c ON c.food_id = l.food_id
+		JOIN foods f ON f.id = l.food_id
+		ORDER BY ` + orderBy + `
+		LIMIT $7
+	`
+
+	var from, to *int
+	if hours, ok := TimesOfDay[q.TimeOfDay]; ok {
+		from, to = &hours[0], &hours[1]
+	}
+
+	location := "UTC"
+	if q.Location != nil {
+		location = q.Location.String()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	since := time.Now().Add(-suggestionWindow)
+	rows, err := s.db.QueryContext(ctx, query, userID, since, q.MealName, from, to, location, suggestionLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	foods := []LoggedFood{}
+	for rows.Next() {
+		var food LoggedFood
+		err := rows.Scan(
+			&food.FoodID,
+			&food.Name,
+			&food.Brand,
+			&food.ServingUnit,
+			&food.Amount,
+			&food.LastLoggedAt,
+			&food.TimesLogged,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		foods = append(foods, food)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return foods, nil
+}
+
+// MealTypes lists the meal_type enum values in the order they are eaten.
+var MealTypes = []string{"breakfast", "lunch", "dinner", "snacks"}
+
+type NutritionTotals struct {
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+	Fiber    float64 `json:"fiber"`
+	// Micronutrients is nil for targets, which only cover the macros.
+	Micronutrients *Micronutrients `json:"micronutrients,omitempty"`
+}
+
+func (t *NutritionTotals) Add(other NutritionTotals) {
+	t.Calories += other.Calories
+	t.Protein += other.Protein
+	t.Carbs += other.Carbs
+	t.Fat += other.Fat
+	t.Fiber += other.Fiber
+
+	if other.Micronutrients != nil {
+		if t.Micronutrients == nil {
+			t.Micronutrients = &Micronutrients{}
+		}
+		t.Micronutrients.add(*other.Micronutrients)
+	}
+}
+
+// Sub returns what is left of t once other is taken away, negative values
+// mean other went over. Only the macros are compared.
+func (t NutritionTotals) Sub(other NutritionTotals) NutritionTotals {
+	return NutritionTotals{
+		Calories: t.Calories - other.Calories,
+		Protein:  t.Protein - other.Protein,
+		Carbs:    t.Carbs - other.Carbs,
+		Fat:      t.Fat - other.Fat,
+		Fiber:    t.Fiber - other.Fiber,
+	}
+}
+
+type DiaryFood struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Brand       string    `json:"brand"`
+	ServingSize float64   `json:"serving_size"`
+	ServingUnit string    `json:"serving_unit"`
+}
+
+type DiaryRecipe struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Servings float64   `json:"servings"`
+}
+
+// DiaryEntry has either Food or Recipe set, matching what the entry logs.
+type DiaryEntry struct {
+	MealEntry
+	Food      *DiaryFood      `json:"food,omitempty"`
+	Recipe    *DiaryRecipe    `json:"recipe,omitempty"`
+	Nutrition NutritionTotals `json:"nutrition"`
+	// Unconvertible is set when the amount can no longer be converted to the
+	// food's serving unit, e.g. after its density was cleared. The entry has
+	// no nutrition and is left out of the totals.
+	Unconvertible bool `json:"unconvertible,omitempty"`
+}
+
+type DiaryMeal struct {
+	ID      *uuid.UUID      `json:"id"`
+	Name    string          `json:"name"`
+	Entries []DiaryEntry    `json:"entries"`
+	Totals  NutritionTotals `json:"totals"`
+}
+
+type DiaryDay struct {
+	Date      string           `json:"date"`
+	Meals     []DiaryMeal      `json:"meals"`
+	Totals    NutritionTotals  `json:"totals"`
+	Targets   *NutritionTotals `json:"targets,omitempty"`
+	Remaining *NutritionTotals `json:"remaining,omitempty"`
+}
+
+// SetTargets records the targets the day is logged against and what is left
+// of them after the day's totals.
+func (d *DiaryDay) SetTargets(targets NutritionTotals) {
+	remaining := targets.Sub(d.Totals)
+
+	d.Targets = &targets
+	d.Remaining = &remaining
+}
+
+// scaleNutrition returns the nutrition of amount units of a food whose values
+// are stored per servingSize.
+func scaleNutrition(per NutritionTotals, servingSize, amount float64) NutritionTotals {
+	factor := amount / servingSize
+
+	scaled := NutritionTotals{
+		Calories: per.Calories * factor,
+		Protein:  per.Protein * factor,
+		Carbs:    per.Carbs * factor,
+		Fat:      per.Fat * factor,
+		Fiber:    per.Fiber * factor,
+	}
+	if per.Micronutrients != nil {
+		micronutrients := per.Micronutrients.scale(factor)
+		scaled.Micronutrients = &micronutrients
+	}
+
+	return scaled
+}
+
+func (s *MealStore) GetDiary(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]DiaryDay, error) {
+	ingredients, err := s.getRecipeEntryIngredients(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT to_char(m.date, 'YYYY-MM-DD'), m.id, m.name,
+			e.id, e.meal_id, e.food_id, e.recipe_id, e.serving_unit, COALESCE(e.legacy_unit, ''), e.amount, e.consumed_at, e.created_at, e.updated_at,
+			COALESCE(fv.name, f.name, ''), COALESCE(fv.brand, f.brand, ''), COALESCE(fv.serving_size, f.serving_size, 1),
+			COALESCE(fv.serving_unit, f.serving_unit, ''),
+			COALESCE(rv.name, r.name, ''), COALESCE(rv.servings, r.servings, 1),
+			COALESCE(fv.calories, f.calories, 0), COALESCE(fv.protein, f.protein, 0),
+			COALESCE(fv.carbs, f.carbs, 0), COALESCE(fv.fat, f.fat, 0), COALESCE(fv.fiber, f.fiber, 0),
+			COALESCE(fv.sugar, f.sugar, 0), COALESCE(fv.saturated_fat, f.saturated_fat, 0),
+			COALESCE(fv.sodium, f.sodium, 0), COALESCE(fv.cholesterol, f.cholesterol, 0),
+			COALESCE(fv.potassium, f.potassium, 0), COALESCE(fv.vitamin_a, f.vitamin_a, 0),
+			COALESCE(fv.vitamin_c, f.vitamin_c, 0), COALESCE(fv.vitamin_d, f.vitamin_d, 0),
+			CASE WHEN fv.serving_unit IS NULL THEN f.density ELSE fv.density END, fp.amount, fp.unit
+		FROM meals m
+		JOIN meal_entries e ON e.meal_id = m.id
+		-- entries of a merged food are totalled against the food they were logged with
+		LEFT JOIN foods f ON f.id = COALESCE(e.logged_food_id, e.food_id)
+		LEFT JOIN recipes r ON r.id = e.recipe_id
+		LEFT JOIN food_portions fp ON fp.food_id = f.id AND fp.name = lower(trim(e.serving_unit))
+	` + foodRevisionJoin + recipeRevisionJoin + `
+		WHERE m.user_id = $1 AND m.date BETWEEN $2 AND $3
+		ORDER BY m.date, m.name, e.consumed_at, e.created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// lay out every day and meal type up front so empty ones are still returned
+	var days []DiaryDay
+	index := make(map[string]*DiaryMeal)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := DiaryDay{Date: d.Format(time.DateOnly), Totals: NutritionTotals{Micronutrients: &Micronutrients{}}}
+		for _, name := range MealTypes {
+			day.Meals = append(day.Meals, DiaryMeal{
+				Name:    name,
+				Entries: []DiaryEntry{},
+				Totals:  NutritionTotals{Micronutrients: &Micronutrients{}},
+			})
+		}
+		days = append(days, day)
+	}
+	for i := range days {
+		for j := range days[i].Meals {
+			index[days[i].Date+"/"+days[i].Meals[j].Name] = &days[i].Meals[j]
+		}
+	}
+
+	for rows.Next() {
+		var date, mealName string
+		var mealID uuid.UUID
+		var entry DiaryEntry
+		var food DiaryFood
+		var recipe DiaryRecipe
+		per := NutritionTotals{Micronutrients: &Micronutrients{}}
+		var density, portionAmount *float64
+		var portionUnit *string
+		err := rows.Scan(
+			&date,
+			&mealID,
+			&mealName,
+			&entry.ID,
+			&entry.MealID,
+			&entry.FoodID,
+			&entry.RecipeID,
+			&entry.ServingUnit,
+			&entry.LegacyUnit,
+			&entry.Amount,
+			&entry.ConsumedAt,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&food.Name,
+			&food.Brand,
+			&food.ServingSize,
+			&food.ServingUnit,
+			&recipe.Name,
+			&recipe.Servings,
+			&per.Calories,
+			&per.Protein,
+			&per.Carbs,
+			&per.Fat,
+			&per.Fiber,
+			&per.Micronutrients.Sugar,
+			&per.Micronutrients.SaturatedFat,
+			&per.Micronutrients.Sodium,
+			&per.Micronutrients.Cholesterol,
+			&per.Micronutrients.Potassium,
+			&per.Micronutrients.VitaminA,
+			&per.Micronutrients.VitaminC,
+			&per.Micronutrients.VitaminD,
+			&density,
+			&portionAmount,
+			&portionUnit,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		meal, ok := index[date+"/"+mealName]
+		if !ok {
+			continue
+		}
+
+		// recipe entries count servings of the recipe as it was when they were logged
+		if entry.RecipeID != nil {
+			recipe.ID = *entry.RecipeID
+			entry.Recipe = &recipe
+			for _, ingredient := range ingredients[entry.ID] {
+				entry.Unconvertible = entry.Unconvertible || ingredient.Unconvertible
+			}
+			if !entry.Unconvertible {
+				entry.Nutrition = scaleNutrition(perServing(ingredients[entry.ID], recipe.Servings), 1, entry.Amount)
+			}
+		} else {
+			food.ID = *entry.FoodID
+			entry.Food = &food
+
+			measure := loggedMeasure(food.ServingUnit, food.ServingSize, density, entry.ServingUnit, portionAmount, portionUnit)
+			amount, err := measure.ServingUnits(entry.Amount, entry.ServingUnit)
+			if err != nil {
+				entry.Unconvertible = true
+			} else {
+				entry.Nutrition = scaleNutrition(per, food.ServingSize, amount)
+			}
+		}
+
+		meal.ID = &mealID
+		meal.Entries = append(meal.Entries, entry)
+		meal.Totals.Add(entry.Nutrition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range days {
+		for _, meal := range days[i].Meals {
+			days[i].Totals.Add(meal.Totals)
+		}
+	}
+
+	return days, nil
+}
+
+// getRecipeEntryIngredients fetches the ingredients of the recipes logged in
+// the range, keyed by entry, as they were when each entry was logged.
+func (s *MealStore) getRecipeEntryIngredients(ctx context.Context, userID uuid.UUID, from, to time.Time) (map[uuid.UUID][]RecipeIngredient, error) {
+	query := `
+		SELECT e.id, ` + recipeIngredientColumns + `
+		FROM meals m
+		JOIN meal_entries e ON e.meal_id = m.id
+		JOIN recipes r ON r.id = e.recipe_id
+	` + recipeIngredientsAt("e.created_at") + `
+		WHERE m.user_id = $1 AND m.date BETWEEN $2 AND $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := make(map[uuid.UUID][]RecipeIngredient)
+	for rows.Next() {
+		var entryID uuid.UUID
+		ingredient, err := scanIngredient(rows, &entryID)
+		if err != nil {
+			return nil, err
+		}
+
+		ingredients[entryID] = append(ingredients[entryID], ingredient)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
@@ -0,0 +1,383 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownUnit  = errors.New("unknown measurement unit")
+	ErrUnitMismatch = errors.New("unit does not fit the measurement type")
+)
+
+const (
+	MeasurementWeight  = "weight"
+	MeasurementBodyFat = "body_fat"
+)
+
+type measurementUnit struct {
+	base   string
+	toBase float64
+}
+
+// measurementUnits maps every accepted unit to the one values are stored in.
+var measurementUnits = map[string]measurementUnit{
+	"kg": {"kg", 1},
+	"lb": {"kg", 0.45359237},
+	"st": {"kg", 6.35029318},
+	"cm": {"cm", 1},
+	"mm": {"cm", 0.1},
+	"in": {"cm", 2.54},
+	"%":  {"%", 1},
+}
+
+// measurementBaseUnits pins the well known types to a dimension, any other
+// type is taken to be whatever its unit says.
+var measurementBaseUnits = map[string]string{
+	MeasurementWeight:  "kg",
+	MeasurementBodyFat: "%",
+}
+
+// NormalizeMeasurement converts value from unit to the unit the measurement
+// type is stored in.
+func NormalizeMeasurement(measurementType string, value float64, unit string) (float64, string, error) {
+	u, ok := measurementUnits[unit]
+	if !ok {
+		return 0, "", ErrUnknownUnit
+	}
+
+	if base, ok := measurementBaseUnits[measurementType]; ok && base != u.base {
+		return 0, "", ErrUnitMismatch
+	}
+
+	return math.Round(value*u.toBase*100) / 100, u.base, nil
+}
+
+type Measurement struct {
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Type         string    `json:"type"`
+	Value        float64   `json:"value"`
+	Unit         string    `json:"unit"`
+	EnteredValue float64   `json:"entered_value"`
+	EnteredUnit  string    `json:"entered_unit"`
+	Notes        string    `json:"notes"`
+	MeasuredAt   string    `json:"measured_at"`
+	CreatedAt    string    `json:"created_at"`
+}
+
+type MeasurementPage struct {
+	Measurements []Measurement `json:"measurements"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+type measurementCursor struct {
+	MeasuredAt time.Time `json:"m"`
+	ID         uuid.UUID `json:"i"`
+}
+
+type TrendPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+	Trend float64 `json:"trend"`
+}
+
+type MeasurementTrend struct {
+	Type   string       `json:"type"`
+	Unit   string       `json:"unit"`
+	Points []TrendPoint `json:"points"`
+	// Current is the latest smoothed value, nil until something is logged.
+	Current *float64 `json:"current"`
+	// WeeklyRate is the change of the trend per week over the last four weeks.
+	WeeklyRate *float64 `json:"weekly_rate"`
+}
+
+// trendSmoothing is the share of a new day's value that goes into the trend,
+// low enough that daily water weight swings don't show up as real change.
+const trendSmoothing = 0.1
+
+// rateWindowDays is how far back the weekly rate of change looks.
+const rateWindowDays = 28
+
+// smoothTrend computes an exponential moving average of daily values ordered
+// oldest first. Gaps between days decay the trend as if the missing days had
+// repeated the next logged value, so sparse logging doesn't lag behind.
+func smoothTrend(points []TrendPoint) []TrendPoint {
+	var prev time.Time
+	for i := range points {
+		date, _ := time.Parse(time.DateOnly, points[i].Date)
+
+		if i == 0 {
+			points[i].Trend = points[i].Value
+		} else {
+			days := date.Sub(prev).Hours() / 24
+			weight := 1 - math.Pow(1-trendSmoothing, days)
+			points[i].Trend = points[i-1].Trend + weight*(points[i].Value-points[i-1].Trend)
+		}
+
+		prev = date
+	}
+
+	for i := range points {
+		points[i].Trend = math.Round(points[i].Trend*100) / 100
+	}
+
+	return points
+}
+
+// weeklyRate fits a line through the trend of the last rateWindowDays and
+// returns its slope per week, nil when there is not enough data to tell.
+func weeklyRate(points []TrendPoint) *float64 {
+	if len(points) < 2 {
+		return nil
+	}
+
+	last, _ := time.Parse(time.DateOnly, points[len(points)-1].Date)
+	since := last.AddDate(0, 0, -rateWindowDays)
+
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, point := range points {
+		date, _ := time.Parse(time.DateOnly, point.Date)
+		if date.Before(since) {
+			continue
+		}
+
+		x := date.Sub(since).Hours() / 24
+		n++
+		sumX += x
+		sumY += point.Trend
+		sumXY += x * point.Trend
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if n < 2 || denominator == 0 {
+		return nil
+	}
+
+	rate := math.Round((n*sumXY-sumX*sumY)/denominator*7*100) / 100
+	return &rate
+}
+
+type MeasurementStore struct {
+	db *sql.DB
+}
+
+func (s *MeasurementStore) Create(ctx context.Context, measurement *Measurement) error {
+	query := `
+		INSERT INTO measurements (user_id, type, value, unit, entered_value, entered_unit, notes, measured_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		measurement.UserID,
+		measurement.Type,
+		measurement.Value,
+		measurement.Unit,
+		measurement.EnteredValue,
+		measurement.EnteredUnit,
+		measurement.Notes,
+		measurement.MeasuredAt,
+	).Scan(
+		&measurement.ID,
+		&measurement.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *MeasurementStore) GetByID(ctx context.Context, id uuid.UUID) (*Measurement, error) {
+	query := `
+		SELECT id, user_id, type, value, unit, entered_value, entered_unit, notes, measured_at, created_at
+		FROM measurements
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var measurement Measurement
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&measurement.ID,
+		&measurement.UserID,
+		&measurement.Type,
+		&measurement.Value,
+		&measurement.Unit,
+		&measurement.EnteredValue,
+		&measurement.EnteredUnit,
+		&measurement.Notes,
+		&measurement.MeasuredAt,
+		&measurement.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &measurement, nil
+}
+
+func (s *MeasurementStore) GetByUser(ctx context.Context, userID uuid.UUID, fq PaginatedMeasurementQuery) (*MeasurementPage, error) {
+	var cursor measurementCursor
+	var cursorMeasuredAt *time.Time
+	if fq.Cursor != "" {
+		if err := decodeCursor(fq.Cursor, &cursor); err != nil {
+			return nil, err
+		}
+
+		cursorMeasuredAt = &cursor.MeasuredAt
+	}
+
+	query := `
+		SELECT id, user_id, type, value, unit, entered_value, entered_unit, notes, measured_at, created_at
+		FROM measurements
+		WHERE user_id = $1
+			AND ($2 = '' OR type = $2)
+			AND ($3::timestamptz IS NULL OR (measured_at, id) < ($3, $4))
+		ORDER BY measured_at DESC, id DESC
+		LIMIT $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// fetch one extra row to know whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, fq.Type, cursorMeasuredAt, cursor.ID, fq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &MeasurementPage{Measurements: []Measurement{}}
+	var last measurementCursor
+	for rows.Next() {
+		var measurement Measurement
+		var measuredAt time.Time
+		err := rows.Scan(
+			&measurement.ID,
+			&measurement.UserID,
+			&measurement.Type,
+			&measurement.Value,
+			&measurement.Unit,
+			&measurement.EnteredValue,
+			&measurement.EnteredUnit,
+			&measurement.Notes,
+			&measuredAt,
+			&measurement.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Measurements) == fq.Limit {
+			next, err := encodeCursor(last)
+			if err != nil {
+				return nil, err
+			}
+
+			page.NextCursor = next
+			break
+		}
+
+		measurement.MeasuredAt = measuredAt.Format(time.RFC3339Nano)
+		page.Measurements = append(page.Measurements, measurement)
+		last = measurementCursor{MeasuredAt: measuredAt, ID: measurement.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (s *MeasurementStore) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM measurements WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetTrend smooths the daily averages of a measurement type and returns the
+// points from the given day on. The whole history goes into the average so the
+// first returned points are already warmed up.
+func (s *MeasurementStore) GetTrend(ctx context.Context, userID uuid.UUID, measurementType string, from time.Time) (*MeasurementTrend, error) {
+	query := `
+		SELECT to_char((measured_at AT TIME ZONE 'UTC')::date, 'YYYY-MM-DD') AS day, MIN(unit), ROUND(AVG(value), 2)
+		FROM measurements
+		WHERE user_id = $1 AND type = $2
+			-- custom types could have been logged in several dimensions, chart
+			-- the one in use now
+			AND unit = (
+				SELECT unit FROM measurements
+				WHERE user_id = $1 AND type = $2
+				ORDER BY measured_at DESC
+				LIMIT 1
+			)
+		GROUP BY day
+		ORDER BY day ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, measurementType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trend := &MeasurementTrend{Type: measurementType, Unit: measurementBaseUnits[measurementType]}
+	var points []TrendPoint
+	for rows.Next() {
+		var point TrendPoint
+		if err := rows.Scan(&point.Date, &trend.Unit, &point.Value); err != nil {
+			return nil, err
+		}
+
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	points = smoothTrend(points)
+	if len(points) > 0 {
+		trend.Current = &points[len(points)-1].Trend
+	}
+	trend.WeeklyRate = weeklyRate(points)
+
+	trend.Points = []TrendPoint{}
+	for _, point := range points {
+		if point.Date >= from.Format(time.DateOnly) {
+			trend.Points = append(trend.Points, point)
+		}
+	}
+
+	return trend, nil
+}
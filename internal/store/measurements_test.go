@@ -0,0 +1,112 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSmoothTrend(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []TrendPoint
+		want   []float64
+	}{
+		{"nothing logged", nil, nil},
+		{"first day is the value", []TrendPoint{{Date: "2024-03-01", Value: 80}}, []float64{80}},
+		{
+			"steady values stay put",
+			[]TrendPoint{{Date: "2024-03-01", Value: 80}, {Date: "2024-03-02", Value: 80}, {Date: "2024-03-05", Value: 80}},
+			[]float64{80, 80, 80},
+		},
+		{
+			"next day moves a tenth of the way",
+			[]TrendPoint{{Date: "2024-03-01", Value: 80}, {Date: "2024-03-02", Value: 81}},
+			[]float64{80, 80.1},
+		},
+		{
+			"a gap decays as if the value repeated",
+			[]TrendPoint{{Date: "2024-03-01", Value: 80}, {Date: "2024-03-03", Value: 82}},
+			[]float64{80, 80.38},
+		},
+		{
+			"builds on the previous trend",
+			[]TrendPoint{{Date: "2024-03-01", Value: 80}, {Date: "2024-03-02", Value: 81}, {Date: "2024-03-03", Value: 81}},
+			[]float64{80, 80.1, 80.19},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smoothTrend(tt.points)
+			if len(got) != len(tt.want) {
+				t.Fatalf("smoothTrend() returned %d points, want %d", len(got), len(tt.want))
+			}
+
+			for i, point := range got {
+				if math.Abs(point.Trend-tt.want[i]) > 1e-9 {
+					t.Errorf("point %d trend = %v, want %v", i, point.Trend, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWeeklyRate(t *testing.T) {
+	// daily trend points from start, rising by step a day
+	line := func(start string, days int, from, step float64) []TrendPoint {
+		date, _ := time.Parse(time.DateOnly, start)
+
+		points := make([]TrendPoint, days)
+		for i := range points {
+			points[i] = TrendPoint{
+				Date:  date.AddDate(0, 0, i).Format(time.DateOnly),
+				Trend: from + float64(i)*step,
+			}
+		}
+
+		return points
+	}
+
+	rate := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		points []TrendPoint
+		want   *float64
+	}{
+		{"nothing logged", nil, nil},
+		{"a single day", line("2024-03-01", 1, 80, 0), nil},
+		{"flat", line("2024-03-01", 10, 80, 0), rate(0)},
+		{"losing", line("2024-03-01", 14, 80, -0.1), rate(-0.7)},
+		{"gaining", line("2024-03-01", 28, 70, 0.05), rate(0.35)},
+		{
+			"only looks at the last four weeks",
+			append([]TrendPoint{{Date: "2024-01-01", Trend: 100}}, line("2024-03-01", 14, 80, -0.1)...),
+			rate(-0.7),
+		},
+		{
+			"the last day alone in the window",
+			[]TrendPoint{{Date: "2024-01-01", Trend: 82}, {Date: "2024-03-01", Trend: 80}},
+			nil,
+		},
+		{
+			"the same day twice",
+			[]TrendPoint{{Date: "2024-03-01", Trend: 80}, {Date: "2024-03-01", Trend: 81}},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := weeklyRate(tt.points)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("weeklyRate() = %v, want %v", got, tt.want)
+			case math.Abs(*got-*tt.want) > 1e-9:
+				t.Errorf("weeklyRate() = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrMissingBodyFat = errors.New("the katch_mcardle formula needs a body fat percentage")
+
+type Sex string
+
+const (
+	SexMale   Sex = "male"
+	SexFemale Sex = "female"
+)
+
+type ActivityLevel string
+
+const (
+	ActivitySedentary  ActivityLevel = "sedentary"
+	ActivityLight      ActivityLevel = "light"
+	ActivityModerate   ActivityLevel = "moderate"
+	ActivityActive     ActivityLevel = "active"
+	ActivityVeryActive ActivityLevel = "very_active"
+)
+
+// activityMultipliers are the usual factors to go from BMR to TDEE.
+var activityMultipliers = map[ActivityLevel]float64{
+	ActivitySedentary:  1.2,
+	ActivityLight:      1.375,
+	ActivityModerate:   1.55,
+	ActivityActive:     1.725,
+	ActivityVeryActive: 1.9,
+}
+
+type WeightGoal string
+
+const (
+	GoalLose     WeightGoal = "lose"
+	GoalMaintain WeightGoal = "maintain"
+	GoalGain     WeightGoal = "gain"
+)
+
+type BMRFormula string
+
+const (
+	MifflinStJeor BMRFormula = "mifflin_st_jeor"
+	KatchMcArdle  BMRFormula = "katch_mcardle"
+)
+
+// kcalPerKg is the energy in a kilogram of body weight, used to turn a weekly
+// goal rate into a daily calorie surplus or deficit.
+const kcalPerKg = 7700
+
+// MinTargetCalories is the lowest daily target an estimate suggests, aggressive
+// goals for small or light users would otherwise go below a safe intake.
+const MinTargetCalories = 1200
+
+type NutritionProfile struct {
+	UserID         uuid.UUID     `json:"user_id"`
+	Sex            Sex           `json:"sex"`
+	BirthDate      string        `json:"birth_date"`
+	HeightCm       float64       `json:"height_cm"`
+	WeightKg       float64       `json:"weight_kg"`
+	BodyFatPercent *float64      `json:"body_fat_percent"`
+	ActivityLevel  ActivityLevel `json:"activity_level"`
+	Goal           WeightGoal    `json:"goal"`
+	GoalRateKg     float64       `json:"goal_rate_kg"`
+	Formula        BMRFormula    `json:"bmr_formula"`
+	CreatedAt      string        `json:"created_at"`
+	UpdatedAt      string        `json:"updated_at"`
+}
+
+type EnergyEstimate struct {
+	BMR            float64 `json:"bmr"`
+	TDEE           float64 `json:"tdee"`
+	TargetCalories float64 `json:"target_calories"`
+}
+
+// Age returns the age in whole years on the given day.
+func (p *NutritionProfile) Age(on time.Time) (int, error) {
+	birth, err := time.Parse(time.DateOnly, p.BirthDate)
+	if err != nil {
+		return 0, err
+	}
+
+	age := on.Year() - birth.Year()
+	if on.Month() < birth.Month() || (on.Month() == birth.Month() && on.Day() < birth.Day()) {
+		age--
+	}
+
+	return age, nil
+}
+
+// BMR estimates the basal metabolic rate in kcal/day. Mifflin-St Jeor works
+// from height, weight, age and sex while Katch-McArdle only needs lean mass.
+func (p *NutritionProfile) BMR(on time.Time) (float64, error) {
+	switch p.Formula {
+	case KatchMcArdle:
+		if p.BodyFatPercent == nil {
+			return 0, ErrMissingBodyFat
+		}
+
+		leanMass := p.WeightKg * (1 - *p.BodyFatPercent/100)
+		return 370 + 21.6*leanMass, nil
+	default:
+		age, err := p.Age(on)
+		if err != nil {
+			return 0, err
+		}
+
+		bmr := 10*p.WeightKg + 6.25*p.HeightCm - 5*float64(age)
+		if p.Sex == SexMale {
+			return bmr + 5, nil
+		}
+
+		return bmr - 161, nil
+	}
+}
+
+// Estimate computes the BMR, the TDEE for the activity level and the daily
+// calories that reach the weight goal at the chosen weekly rate.
+func (p *NutritionProfile) Estimate(on time.Time) (EnergyEstimate, error) {
+	bmr, err := p.BMR(on)
+	if err != nil {
+		return EnergyEstimate{}, err
+	}
+
+	tdee := bmr * activityMultipliers[p.ActivityLevel]
+
+	target := tdee
+	switch p.Goal {
+	case GoalLose:
+		target -= p.GoalRateKg * kcalPerKg / 7
+	case GoalGain:
+		target += p.GoalRateKg * kcalPerKg / 7
+	}
+	target = math.Max(target, MinTargetCalories)
+
+	return EnergyEstimate{
+		BMR:            math.Round(bmr),
+		TDEE:           math.Round(tdee),
+		TargetCalories: math.Round(target),
+	}, nil
+}
+
+type MacroSplit struct {
+	Protein float64 `json:"protein"`
+	Carbs   float64 `json:"carbs"`
+	Fat     float64 `json:"fat"`
+}
+
+type NutritionTargets struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	EffectiveFrom string    `json:"effective_from"`
+	NutritionTotals
+	// Percentages is set when the targets were given as a share of calories.
+	Percentages *MacroSplit `json:"percentages,omitempty"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+// FiberTarget follows the common recommendation of 14g per 1000 kcal.
+func FiberTarget(calories float64) float64 {
+	return math.Round(calories / 1000 * 14)
+}
+
+// MacrosFromPercentages converts a calorie split to grams, at 4 kcal/g for
+// protein and carbs and 9 kcal/g for fat.
+func MacrosFromPercentages(calories float64, split MacroSplit) NutritionTotals {
+	return NutritionTotals{
+		Calories: calories,
+		Protein:  math.Round(calories * split.Protein / 100 / 4),
+		Carbs:    math.Round(calories * split.Carbs / 100 / 4),
+		Fat:      math.Round(calories * split.Fat / 100 / 9),
+		Fiber:    FiberTarget(calories),
+	}
+}
+
+// SuggestMacros splits calories into protein by body weight (more while
+// cutting to hold on to muscle), a quarter of the calories from fat and the
+// rest from carbs.
+func SuggestMacros(calories, weightKg float64, goal WeightGoal) NutritionTotals {
+	proteinPerKg := 1.8
+	if goal == GoalLose {
+		proteinPerKg = 2.2
+	}
+
+	protein := math.Round(weightKg * proteinPerKg)
+	fat := math.Round(calories * 0.25 / 9)
+	carbs := math.Max(0, math.Round((calories-protein*4-fat*9)/4))
+
+	return NutritionTotals{
+		Calories: calories,
+		Protein:  protein,
+		Carbs:    carbs,
+		Fat:      fat,
+		Fiber:    FiberTarget(calories),
+	}
+}
+
+// TargetsOn picks the targets in effect on date (YYYY-MM-DD) out of a history
+// ordered newest first.
+func TargetsOn(history []NutritionTargets, date string) *NutritionTargets {
+	for i := range history {
+		if history[i].EffectiveFrom <= date {
+			return &history[i]
+		}
+	}
+
+	return nil
+}
+
+type NutritionStore struct {
+	db *sql.DB
+}
+
+func (s *NutritionStore) GetProfile(ctx context.Context, userID uuid.UUID) (*NutritionProfile, error) {
+	query := `
+		SELECT user_id, sex, to_char(birth_date, 'YYYY-MM-DD'), height_cm, weight_kg, body_fat_percent,
+			activity_level, goal, goal_rate_kg, bmr_formula, created_at, updated_at
+		FROM nutrition_profiles
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var profile NutritionProfile
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&profile.UserID,
+		&profile.Sex,
+		&profile.BirthDate,
+		&profile.HeightCm,
+		&profile.WeightKg,
+		&profile.BodyFatPercent,
+		&profile.ActivityLevel,
+		&profile.Goal,
+		&profile.GoalRateKg,
+		&profile.Formula,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &profile, nil
+}
+
+func (s *NutritionStore) UpsertProfile(ctx context.Context, profile *NutritionProfile) error {
+	query := `
+		INSERT INTO nutrition_profiles (user_id, sex, birth_date, height_cm, weight_kg, body_fat_percent,
+			activity_level, goal, goal_rate_kg, bmr_formula)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE SET
+			sex = EXCLUDED.sex,
+			birth_date = EXCLUDED.birth_date,
+			height_cm = EXCLUDED.height_cm,
+			weight_kg = EXCLUDED.weight_kg,
+			body_fat_percent = EXCLUDED.body_fat_percent,
+			activity_level = EXCLUDED.activity_level,
+			goal = EXCLUDED.goal,
+			goal_rate_kg = EXCLUDED.goal_rate_kg,
+			bmr_formula = EXCLUDED.bmr_formula,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		profile.UserID,
+		profile.Sex,
+		profile.BirthDate,
+		profile.HeightCm,
+		profile.WeightKg,
+		profile.BodyFatPercent,
+		profile.ActivityLevel,
+		profile.Goal,
+		profile.GoalRateKg,
+		profile.Formula,
+	).Scan(
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateBodyComposition keeps the profile in line with logged measurements, nil
+// values are left as they are. Users without a profile are left alone.
+func (s *NutritionStore) UpdateBodyComposition(ctx context.Context, userID uuid.UUID, weightKg, bodyFatPercent *float64) error {
+	query := `
+		UPDATE nutrition_profiles
+		SET weight_kg = COALESCE($2, weight_kg),
+			body_fat_percent = COALESCE($3, body_fat_percent),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, weightKg, bodyFatPercent)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *NutritionStore) CreateTargets(ctx context.Context, targets *NutritionTargets) error {
+	query := `
+		INSERT INTO nutrition_targets (user_id, effective_from, calories, protein, carbs, fat, fiber,
+			protein_percent, carbs_percent, fat_percent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at
+	`
+
+	var proteinPercent, carbsPercent, fatPercent *float64
+	if targets.Percentages != nil {
+		proteinPercent = &targets.Percentages.Protein
+		carbsPercent = &targets.Percentages.Carbs
+		fatPercent = &targets.Percentages.Fat
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		targets.UserID,
+		targets.EffectiveFrom,
+		targets.Calories,
+		targets.Protein,
+		targets.Carbs,
+		targets.Fat,
+		targets.Fiber,
+		proteinPercent,
+		carbsPercent,
+		fatPercent,
+	).Scan(
+		&targets.ID,
+		&targets.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetTargetsHistory returns every target the user has set, newest first. When
+// several take effect on the same day the last one created wins.
+func (s *NutritionStore) GetTargetsHistory(ctx context.Context, userID uuid.UUID) ([]NutritionTargets, error) {
+	query := `
+		SELECT id, user_id, to_char(effective_from, 'YYYY-MM-DD'), calories, protein, carbs, fat, fiber,
+			protein_percent, carbs_percent, fat_percent, created_at
+		FROM nutrition_targets
+		WHERE user_id = $1
+		ORDER BY effective_from DESC, created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []NutritionTargets{}
+	for rows.Next() {
+		var targets NutritionTargets
+		var proteinPercent, carbsPercent, fatPercent sql.NullFloat64
+		err := rows.Scan(
+			&targets.ID,
+			&targets.UserID,
+			&targets.EffectiveFrom,
+			&targets.Calories,
+			&targets.Protein,
+			&targets.Carbs,
+			&targets.Fat,
+			&targets.Fiber,
+			&proteinPercent,
+			&carbsPercent,
+			&fatPercent,
+			&targets.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if proteinPercent.Valid && carbsPercent.Valid && fatPercent.Valid {
+			targets.Percentages = &MacroSplit{
+				Protein: proteinPercent.Float64,
+				Carbs:   carbsPercent.Float64,
+				Fat:     fatPercent.Float64,
+			}
+		}
+
+		history = append(history, targets)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+type PaginatedQuery struct {
+	Limit  int `validate:"gte=1,lte=50"`
+	Cursor string
+}
+
+func (q PaginatedQuery) Parse(c *fiber.Ctx) (PaginatedQuery, error) {
+	q.Cursor = c.Query("cursor")
+
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+
+		q.Limit = l
+	}
+
+	return q, nil
+}
+
+type PaginatedFeedQuery struct {
+	PaginatedQuery
+	Types []string `validate:"max=3,dive,oneof=workout personal_record meal"`
+}
+
+func (fq PaginatedFeedQuery) Parse(c *fiber.Ctx) (PaginatedFeedQuery, error) {
+	q, err := fq.PaginatedQuery.Parse(c)
+	if err != nil {
+		return fq, err
+	}
+	fq.PaginatedQuery = q
+
+	if types := c.Query("type"); types != "" {
+		fq.Types = strings.Split(types, ",")
+	}
+
+	return fq, nil
+}
+
+type PaginatedMeasurementQuery struct {
+	PaginatedQuery
+	Type string `validate:"max=50"`
+}
+
+func (mq PaginatedMeasurementQuery) Parse(c *fiber.Ctx) (PaginatedMeasurementQuery, error) {
+	q, err := mq.PaginatedQuery.Parse(c)
+	if err != nil {
+		return mq, err
+	}
+	mq.PaginatedQuery = q
+	mq.Type = c.Query("type")
+
+	return mq, nil
+}
+
+type FoodSuggestionQuery struct {
+	MealName  string `validate:"omitempty,oneof=breakfast lunch dinner snacks"`
+	TimeOfDay string `validate:"omitempty,oneof=morning afternoon evening night"`
+	// Location is the time zone entries are bucketed into times of day in.
+	Location *time.Location
+}
+
+func (q FoodSuggestionQuery) Parse(c *fiber.Ctx) (FoodSuggestionQuery, error) {
+	q.MealName = c.Query("meal_name")
+	q.TimeOfDay = c.Query("time_of_day")
+
+	location, err := time.LoadLocation(c.Query("tz", "UTC"))
+	if err != nil {
+		return q, err
+	}
+	q.Location = location
+
+	return q, nil
+}
+
+type NutrientRange struct {
+	Min *float64 `validate:"omitempty,gte=0"`
+	Max *float64 `validate:"omitempty,gte=0"`
+}
+
+type PaginatedFoodQuery struct {
+	Search   string `validate:"max=100"`
+	Brand    string `validate:"max=100"`
+	Verified *bool
+	Calories NutrientRange
+	Protein  NutrientRange
+	Carbs    NutrientRange
+	Fat      NutrientRange
+	Limit    int `validate:"gte=1,lte=50"`
+	Cursor   string
+	// Viewer is the user searching, whose private foods are included.
+	Viewer uuid.UUID
+}
+
+func (fq PaginatedFoodQuery) Parse(c *fiber.Ctx) (PaginatedFoodQuery, error) {
+	fq.Search = c.Query("search")
+	fq.Brand = c.Query("brand")
+	fq.Cursor = c.Query("cursor")
+
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Limit = l
+	}
+
+	if verified := c.Query("verified"); verified != "" {
+		v, err := strconv.ParseBool(verified)
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Verified = &v
+	}
+
+	ranges := map[string]*NutrientRange{
+		"calories": &fq.Calories,
+		"protein":  &fq.Protein,
+		"carbs":    &fq.Carbs,
+		"fat":      &fq.Fat,
+	}
+	for name, r := range ranges {
+		min, err := parseOptionalFloat(c.Query("min_" + name))
+		if err != nil {
+			return fq, err
+		}
+
+		max, err := parseOptionalFloat(c.Query("max_" + name))
+		if err != nil {
+			return fq, err
+		}
+
+		r.Min, r.Max = min, max
+	}
+
+	return fq, nil
+}
+
+func parseOptionalFloat(val string) (*float64, error) {
+	if val == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// encodeCursor turns the sort key of the last row on a page into an opaque
+// token the client hands back to fetch the next page.
+func encodeCursor(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidCursor
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUnknownRoutine   = errors.New("routine not found")
+	ErrProgramCompleted = errors.New("program already completed")
+)
+
+type Program struct {
+	ID              uuid.UUID    `json:"id"`
+	UserID          uuid.UUID    `json:"user_id"`
+	Name            string       `json:"name"`
+	Description     string       `json:"description"`
+	Weeks           int          `json:"weeks"`
+	WeightIncrement float64      `json:"weight_increment"`
+	DeloadEvery     int          `json:"deload_every"`
+	DeloadPercent   float64      `json:"deload_percent"`
+	CreatedAt       string       `json:"created_at"`
+	UpdatedAt       string       `json:"updated_at"`
+	Days            []ProgramDay `json:"days,omitempty"`
+}
+
+type ProgramDay struct {
+	ID          uuid.UUID `json:"id"`
+	ProgramID   uuid.UUID `json:"program_id"`
+	DayNumber   int       `json:"day_number"`
+	RoutineID   uuid.UUID `json:"routine_id"`
+	RoutineName string    `json:"routine_name,omitempty"`
+}
+
+type ProgramEnrollment struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	ProgramID   uuid.UUID `json:"program_id"`
+	CurrentWeek int       `json:"current_week"`
+	CurrentDay  int       `json:"current_day"`
+	StartedAt   string    `json:"started_at"`
+	CompletedAt *string   `json:"completed_at"`
+}
+
+// IsDeloadWeek reports whether the given 1-based week is a deload week.
+func (p *Program) IsDeloadWeek(week int) bool {
+	return p.DeloadEvery > 0 && week%p.DeloadEvery == 0
+}
+
+// TargetWeight applies the weekly progression, and the deload reduction on
+// deload weeks, to a routine's base weight.
+func (p *Program) TargetWeight(base float64, week int) float64 {
+	weight := base + p.WeightIncrement*float64(week-1)
+	if p.IsDeloadWeek(week) {
+		weight = weight * p.DeloadPercent / 100
+	}
+
+	return weight
+}
+
+// Advance moves the enrollment on to the next program day, rolling over into
+// the next week and marking the program completed after its last week.
+func (e *ProgramEnrollment) Advance(program *Program) {
+	e.CurrentDay++
+	if e.CurrentDay > len(program.Days) {
+		e.CurrentDay = 1
+		e.CurrentWeek++
+	}
+
+	if e.CurrentWeek > program.Weeks {
+		completedAt := time.Now().Format(time.RFC3339)
+		e.CompletedAt = &completedAt
+	}
+}
+
+type ProgramStore struct {
+	db *sql.DB
+}
+
+func (s *ProgramStore) Create(ctx context.Context, program *Program) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, program); err != nil {
+			return err
+		}
+
+		return s.createDays(ctx, tx, program)
+	})
+}
+
+func (s *ProgramStore) create(ctx context.Context, tx *sql.Tx, program *Program) error {
+	query := `
+		INSERT INTO programs (user_id, name, description, weeks, weight_increment, deload_every, deload_percent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		program.UserID,
+		program.Name,
+		program.Description,
+		program.Weeks,
+		program.WeightIncrement,
+		program.DeloadEvery,
+		program.DeloadPercent,
+	).Scan(
+		&program.ID,
+		&program.CreatedAt,
+		&program.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *ProgramStore) createDays(ctx context.Context, tx *sql.Tx, program *Program) error {
+	query := `
+		INSERT INTO program_days (program_id, day_number, routine_id)
+		VALUES ($1, $2, $3) RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := range program.Days {
+		day := &program.Days[i]
+		day.ProgramID = program.ID
+
+		err := tx.QueryRowContext(ctx, query, day.ProgramID, day.DayNumber, day.RoutineID).Scan(&day.ID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrUnknownRoutine
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ProgramStore) GetByID(ctx context.Context, id uuid.UUID) (*Program, error) {
+	query := `
+		SELECT id, user_id, name, description, weeks, weight_increment, deload_every, deload_percent, created_at, updated_at
+		FROM programs
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var program Program
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&program.ID,
+		&program.UserID,
+		&program.Name,
+		&program.Description,
+		&program.Weeks,
+		&program.WeightIncrement,
+		&program.DeloadEvery,
+		&program.DeloadPercent,
+		&program.CreatedAt,
+		&program.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	days, err := s.getDays(ctx, program.ID)
+	if err != nil {
+		return nil, err
+	}
+	program.Days = days
+
+	return &program, nil
+}
+
+func (s *ProgramStore) getDays(ctx context.Context, programID uuid.UUID) ([]ProgramDay, error) {
+	query := `
+		SELECT pd.id, pd.program_id, pd.day_number, pd.routine_id, r.name
+		FROM program_days pd
+		JOIN routines r ON r.id = pd.routine_id
+		WHERE pd.program_id = $1
+		ORDER BY pd.day_number ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []ProgramDay{}
+	for rows.Next() {
+		var day ProgramDay
+		err := rows.Scan(
+			&day.ID,
+			&day.ProgramID,
+			&day.DayNumber,
+			&day.RoutineID,
+			&day.RoutineName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+func (s *ProgramStore) Enroll(ctx context.Context, enrollment *ProgramEnrollment) error {
+	query := `
+		INSERT INTO program_enrollments (user_id, program_id)
+		VALUES ($1, $2) RETURNING id, current_week, current_day, started_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		enrollment.UserID,
+		enrollment.ProgramID,
+	).Scan(
+		&enrollment.ID,
+		&enrollment.CurrentWeek,
+		&enrollment.CurrentDay,
+		&enrollment.StartedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *ProgramStore) GetEnrollment(ctx context.Context, userID, programID uuid.UUID) (*ProgramEnrollment, error) {
+	query := `
+		SELECT id, user_id, program_id, current_week, current_day, started_at, completed_at
+		FROM program_enrollments
+		WHERE user_id = $1 AND program_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var enrollment ProgramEnrollment
+	err := s.db.QueryRowContext(ctx, query, userID, programID).Scan(
+		&enrollment.ID,
+		&enrollment.UserID,
+		&enrollment.ProgramID,
+		&enrollment.CurrentWeek,
+		&enrollment.CurrentDay,
+		&enrollment.StartedAt,
+		&enrollment.CompletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &enrollment, nil
+}
+
+// StartWorkout stores the workout planned for the enrollment's current day and
+// advances the enrollment to the next day in the same transaction.
+func (s *ProgramStore) StartWorkout(ctx context.Context, program *Program, enrollment *ProgramEnrollment, workout *Workout) error {
+	if enrollment.CompletedAt != nil {
+		return ErrProgramCompleted
+	}
+
+	workouts := &WorkoutStore{s.db}
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := workouts.create(ctx, tx, workout); err != nil {
+			return err
+		}
+
+		if err := workouts.createSets(ctx, tx, workout); err != nil {
+			return err
+		}
+
+		week, day := enrollment.CurrentWeek, enrollment.CurrentDay
+		enrollment.Advance(program)
+
+		return s.advanceEnrollment(ctx, tx, enrollment, week, day)
+	})
+}
+
+// advanceEnrollment moves the enrollment on from week and day. It fails with
+// ErrConflict when another start advanced it first, so the same day isn't
+// started twice.
+func (s *ProgramStore) advanceEnrollment(ctx context.Context, tx *sql.Tx, enrollment *ProgramEnrollment, week, day int) error {
+	query := `
+		UPDATE program_enrollments
+		SET current_week = $1, current_day = $2, completed_at = $3
+		WHERE id = $4 AND current_week = $5 AND current_day = $6 AND completed_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(
+		ctx,
+		query,
+		enrollment.CurrentWeek,
+		enrollment.CurrentDay,
+		enrollment.CompletedAt,
+		enrollment.ID,
+		week,
+		day,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,486 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/zondaf12/workout-app-backend/internal/units"
+)
+
+var (
+	ErrUnknownFood   = errors.New("food not found")
+	ErrUnknownRecipe = errors.New("recipe not found")
+)
+
+// RecipeServingUnit is the serving unit of meal entries that log a recipe,
+// their amount is a number of servings.
+const RecipeServingUnit = "serving"
+
+type Recipe struct {
+	ID          uuid.UUID          `json:"id"`
+	UserID      uuid.UUID          `json:"user_id"`
+	Name        string             `json:"name"`
+	Notes       string             `json:"notes"`
+	Servings    float64            `json:"servings"`
+	PerServing  NutritionTotals    `json:"per_serving"`
+	CreatedAt   string             `json:"created_at"`
+	UpdatedAt   string             `json:"updated_at"`
+	Ingredients []RecipeIngredient `json:"ingredients,omitempty"`
+}
+
+// RecipeIngredient is Amount of the food in Unit, which converts to the
+// food's ServingUnit like the unit of a meal entry.
+type RecipeIngredient struct {
+	ID          uuid.UUID       `json:"id"`
+	RecipeID    uuid.UUID       `json:"recipe_id"`
+	FoodID      uuid.UUID       `json:"food_id"`
+	FoodName    string          `json:"food_name,omitempty"`
+	ServingUnit string          `json:"serving_unit,omitempty"`
+	Position    int             `json:"position"`
+	Amount      float64         `json:"amount"`
+	Unit        string          `json:"unit"`
+	Nutrition   NutritionTotals `json:"nutrition"`
+	// Unconvertible is set when the amount can no longer be converted to the
+	// food's serving unit, the ingredient then adds no nutrition.
+	Unconvertible bool `json:"unconvertible,omitempty"`
+}
+
+// perServing adds up the ingredients and divides them over the recipe's yield.
+func perServing(ingredients []RecipeIngredient, servings float64) NutritionTotals {
+	total := NutritionTotals{Micronutrients: &Micronutrients{}}
+	for _, ingredient := range ingredients {
+		total.Add(ingredient.Nutrition)
+	}
+
+	return scaleNutrition(total, servings, 1)
+}
+
+// recipeRevisionJoin adds the name and servings the recipe an entry aliased e
+// was logged from had at the time as rv. It is null when the recipe hasn't
+// changed since.
+const recipeRevisionJoin = `
+	LEFT JOIN LATERAL (
+		SELECT rr.name, rr.servings
+		FROM recipe_revisions rr
+		WHERE rr.recipe_id = e.recipe_id AND rr.replaced_at > e.created_at
+		ORDER BY rr.replaced_at ASC
+		LIMIT 1
+	) rv ON true
+`
+
+// recipeIngredientsAt joins the ingredients the recipe aliased r had at the
+// time at, an SQL expression, as ri along with their food as f, the version of
+// the food current then as iv and the portion they are measured in as fp. iv
+// is null when the food hasn't changed since.
+func recipeIngredientsAt(at string) string {
+	return `
+	JOIN recipe_ingredients ri ON ri.recipe_id = r.id
+		AND ri.created_at <= ` + at + ` AND (ri.replaced_at IS NULL OR ri.replaced_at > ` + at + `)
+	JOIN foods f ON f.id = ri.food_id
+	LEFT JOIN food_portions fp ON fp.food_id = ri.food_id AND fp.name = ri.unit
+	LEFT JOIN LATERAL (
+		SELECT fr.name, fr.serving_size, fr.serving_unit, fr.density,
+			fr.calories, fr.protein, fr.carbs, fr.fat, fr.fiber,
+			fr.sugar, fr.saturated_fat, fr.sodium, fr.cholesterol, fr.potassium, fr.vitamin_a, fr.vitamin_c, fr.vitamin_d
+		FROM food_revisions fr
+		WHERE fr.food_id = ri.food_id AND fr.replaced_at > ` + at + `
+		ORDER BY fr.replaced_at ASC
+		LIMIT 1
+	) iv ON true
+`
+}
+
+// recipeIngredientColumns are the columns scanIngredient reads.
+const recipeIngredientColumns = `
+	ri.id, ri.recipe_id, ri.food_id, COALESCE(iv.name, f.name), COALESCE(iv.serving_unit, f.serving_unit), ri.position, ri.amount, ri.unit,
+	CASE WHEN iv.serving_unit IS NULL THEN f.density ELSE iv.density END, fp.amount, fp.unit,
+	COALESCE(iv.serving_size, f.serving_size), COALESCE(iv.calories, f.calories), COALESCE(iv.protein, f.protein),
+	COALESCE(iv.carbs, f.carbs), COALESCE(iv.fat, f.fat), COALESCE(iv.fiber, f.fiber),
+	COALESCE(iv.sugar, f.sugar), COALESCE(iv.saturated_fat, f.saturated_fat), COALESCE(iv.sodium, f.sodium),
+	COALESCE(iv.cholesterol, f.cholesterol), COALESCE(iv.potassium, f.potassium), COALESCE(iv.vitamin_a, f.vitamin_a),
+	COALESCE(iv.vitamin_c, f.vitamin_c), COALESCE(iv.vitamin_d, f.vitamin_d)
+`
+
+// scanIngredient reads recipeIngredientColumns, after any columns of the
+// query that come before them into dest, and works out the ingredient's
+// nutrition.
+func scanIngredient(rows *sql.Rows, dest ...any) (RecipeIngredient, error) {
+	var ingredient RecipeIngredient
+	var servingSize float64
+	var density, portionAmount *float64
+	var portionUnit *string
+	per := NutritionTotals{Micronutrients: &Micronutrients{}}
+	err := rows.Scan(append(dest,
+		&ingredient.ID,
+		&ingredient.RecipeID,
+		&ingredient.FoodID,
+		&ingredient.FoodName,
+		&ingredient.ServingUnit,
+		&ingredient.Position,
+		&ingredient.Amount,
+		&ingredient.Unit,
+		&density,
+		&portionAmount,
+		&portionUnit,
+		&servingSize,
+		&per.Calories,
+		&per.Protein,
+		&per.Carbs,
+		&per.Fat,
+		&per.Fiber,
+		&per.Micronutrients.Sugar,
+		&per.Micronutrients.SaturatedFat,
+		&per.Micronutrients.Sodium,
+		&per.Micronutrients.Cholesterol,
+		&per.Micronutrients.Potassium,
+		&per.Micronutrients.VitaminA,
+		&per.Micronutrients.VitaminC,
+		&per.Micronutrients.VitaminD,
+	)...)
+	if err != nil {
+		return RecipeIngredient{}, err
+	}
+
+	measure := loggedMeasure(ingredient.ServingUnit, servingSize, density, ingredient.Unit, portionAmount, portionUnit)
+	amount, err := measure.ServingUnits(ingredient.Amount, ingredient.Unit)
+	if err != nil {
+		ingredient.Unconvertible = true
+		return ingredient, nil
+	}
+
+	ingredient.Nutrition = scaleNutrition(per, servingSize, amount)
+
+	return ingredient, nil
+}
+
+type RecipeStore struct {
+	db *sql.DB
+}
+
+func (s *RecipeStore) Create(ctx context.Context, recipe *Recipe) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, recipe); err != nil {
+			return err
+		}
+
+		return s.createIngredients(ctx, tx, recipe)
+	})
+}
+
+func (s *RecipeStore) create(ctx context.Context, tx *sql.Tx, recipe *Recipe) error {
+	query := `
+		INSERT INTO recipes (user_id, name, notes, servings)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		recipe.UserID,
+		recipe.Name,
+		recipe.Notes,
+		recipe.Servings,
+	).Scan(
+		&recipe.ID,
+		&recipe.CreatedAt,
+		&recipe.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RecipeStore) createIngredients(ctx context.Context, tx *sql.Tx, recipe *Recipe) error {
+	query := `
+		INSERT INTO recipe_ingredients (recipe_id, food_id, position, amount, unit)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := range recipe.Ingredients {
+		ingredient := &recipe.Ingredients[i]
+		ingredient.RecipeID = recipe.ID
+		ingredient.Unit = units.Normalize(ingredient.Unit)
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			ingredient.RecipeID,
+			ingredient.FoodID,
+			ingredient.Position,
+			ingredient.Amount,
+			ingredient.Unit,
+		).Scan(
+			&ingredient.ID,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrUnknownFood
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RecipeStore) GetByID(ctx context.Context, id uuid.UUID) (*Recipe, error) {
+	query := `
+		SELECT id, user_id, name, notes, servings, created_at, updated_at
+		FROM recipes
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var recipe Recipe
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&recipe.ID,
+		&recipe.UserID,
+		&recipe.Name,
+		&recipe.Notes,
+		&recipe.Servings,
+		&recipe.CreatedAt,
+		&recipe.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	ingredients, err := s.getIngredients(ctx, recipe.ID)
+	if err != nil {
+		return nil, err
+	}
+	recipe.Ingredients = ingredients
+	recipe.PerServing = perServing(recipe.Ingredients, recipe.Servings)
+
+	return &recipe, nil
+}
+
+func (s *RecipeStore) getIngredients(ctx context.Context, recipeID uuid.UUID) ([]RecipeIngredient, error) {
+	query := `
+		SELECT ` + recipeIngredientColumns + `
+		FROM recipes r
+	` + recipeIngredientsAt("CURRENT_TIMESTAMP") + `
+		WHERE r.id = $1
+		ORDER BY ri.position ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := []RecipeIngredient{}
+	for rows.Next() {
+		ingredient, err := scanIngredient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ingredients = append(ingredients, ingredient)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// GetByUser lists the user's recipes with their nutrition per serving but
+// without their ingredients.
+func (s *RecipeStore) GetByUser(ctx context.Context, userID uuid.UUID) ([]Recipe, error) {
+	query := `
+		SELECT id, user_id, name, notes, servings, created_at, updated_at
+		FROM recipes
+		WHERE user_id = $1
+		ORDER BY name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipes := []Recipe{}
+	for rows.Next() {
+		var recipe Recipe
+		err := rows.Scan(
+			&recipe.ID,
+			&recipe.UserID,
+			&recipe.Name,
+			&recipe.Notes,
+			&recipe.Servings,
+			&recipe.CreatedAt,
+			&recipe.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ingredients, err := s.getIngredientsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range recipes {
+		recipes[i].PerServing = perServing(ingredients[recipes[i].ID], recipes[i].Servings)
+	}
+
+	return recipes, nil
+}
+
+func (s *RecipeStore) getIngredientsByUser(ctx context.Context, userID uuid.UUID) (map[uuid.UUID][]RecipeIngredient, error) {
+	query := `
+		SELECT ` + recipeIngredientColumns + `
+		FROM recipes r
+	` + recipeIngredientsAt("CURRENT_TIMESTAMP") + `
+		WHERE r.user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := make(map[uuid.UUID][]RecipeIngredient)
+	for rows.Next() {
+		ingredient, err := scanIngredient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		ingredients[ingredient.RecipeID] = append(ingredients[ingredient.RecipeID], ingredient)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// Update replaces the recipe's details and ingredients, keeping the ones it
+// replaces so entries logged before the edit are still totalled against them.
+func (s *RecipeStore) Update(ctx context.Context, recipe *Recipe) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.saveRevision(ctx, tx, recipe.ID); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE recipes
+			SET name = $1, notes = $2, servings = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $4 RETURNING created_at, updated_at
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			recipe.Name,
+			recipe.Notes,
+			recipe.Servings,
+			recipe.ID,
+		).Scan(
+			&recipe.CreatedAt,
+			&recipe.UpdatedAt,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		replace := `
+			UPDATE recipe_ingredients
+			SET replaced_at = CURRENT_TIMESTAMP
+			WHERE recipe_id = $1 AND replaced_at IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, replace, recipe.ID); err != nil {
+			return err
+		}
+
+		return s.createIngredients(ctx, tx, recipe)
+	})
+}
+
+// saveRevision stores the name and servings the recipe has before an edit.
+func (s *RecipeStore) saveRevision(ctx context.Context, tx *sql.Tx, recipeID uuid.UUID) error {
+	query := `
+		INSERT INTO recipe_revisions (recipe_id, name, servings, valid_from)
+		SELECT id, name, servings, COALESCE(updated_at, created_at)
+		FROM recipes
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, recipeID)
+	return err
+}
+
+func (s *RecipeStore) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM recipes
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		// logged in the diary, which needs the ingredients to total the day
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
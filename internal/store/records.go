@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownFormula = errors.New("unknown one rep max formula")
+
+type OneRepMaxFormula string
+
+const (
+	Epley   OneRepMaxFormula = "epley"
+	Brzycki OneRepMaxFormula = "brzycki"
+)
+
+func ParseOneRepMaxFormula(name string) (OneRepMaxFormula, error) {
+	switch f := OneRepMaxFormula(name); f {
+	case Epley, Brzycki:
+		return f, nil
+	case "":
+		return Epley, nil
+	default:
+		return "", ErrUnknownFormula
+	}
+}
+
+// brzyckiMaxReps is where Brzycki stops being accurate, it heads towards
+// infinity at 37 reps. Longer sets are estimated with Epley instead.
+const brzyckiMaxReps = 10
+
+// Estimate returns the estimated one rep max for lifting weight for reps.
+func (f OneRepMaxFormula) Estimate(weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+
+	if reps == 1 {
+		return weight
+	}
+
+	switch {
+	case f == Brzycki && reps <= brzyckiMaxReps:
+		return weight * 36 / float64(37-reps)
+	default:
+		return weight * (1 + float64(reps)/30)
+	}
+}
+
+type SetRecord struct {
+	WorkoutID  uuid.UUID `json:"workout_id"`
+	AchievedAt string    `json:"achieved_at"`
+	Weight     float64   `json:"weight"`
+	Reps       int       `json:"reps"`
+	Value      float64   `json:"value"`
+}
+
+type ExerciseRecords struct {
+	ExerciseID        uuid.UUID   `json:"exercise_id"`
+	ExerciseName      string      `json:"exercise_name"`
+	HeaviestWeight    SetRecord   `json:"heaviest_weight"`
+	BestOneRepMax     SetRecord   `json:"best_one_rep_max"`
+	BestSessionVolume SetRecord   `json:"best_session_volume"`
+	RepRecords        []SetRecord `json:"rep_records"`
+}
+
+type ExerciseSession struct {
+	WorkoutID     uuid.UUID `json:"workout_id"`
+	StartedAt     string    `json:"started_at"`
+	Sets          int       `json:"sets"`
+	TotalReps     int       `json:"total_reps"`
+	Volume        float64   `json:"volume"`
+	TopWeight     float64   `json:"top_weight"`
+	BestOneRepMax float64   `json:"best_one_rep_max"`
+}
+
+type loggedSet struct {
+	ExerciseID   uuid.UUID
+	ExerciseName string
+	WorkoutID    uuid.UUID
+	StartedAt    string
+	Weight       float64
+	Reps         int
+}
+
+type RecordStore struct {
+	db *sql.DB
+}
+
+// GetByUser computes the personal records of every exercise the user has
+// logged weighted sets for.
+func (s *RecordStore) GetByUser(ctx context.Context, userID uuid.UUID, formula OneRepMaxFormula) ([]ExerciseRecords, error) {
+	sets, err := s.getLoggedSets(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeRecords(sets, formula), nil
+}
+
+// GetExerciseHistory summarises every session in which the user logged the
+// exercise, oldest first.
+func (s *RecordStore) GetExerciseHistory(ctx context.Context, userID, exerciseID uuid.UUID, formula OneRepMaxFormula) ([]ExerciseSession, error) {
+	sets, err := s.getLoggedSets(ctx, userID, &exerciseID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []ExerciseSession{}
+	for _, set := range sets {
+		if len(sessions) == 0 || sessions[len(sessions)-1].WorkoutID != set.WorkoutID {
+			sessions = append(sessions, ExerciseSession{WorkoutID: set.WorkoutID, StartedAt: set.StartedAt})
+		}
+
+		session := &sessions[len(sessions)-1]
+		session.Sets++
+		session.TotalReps += set.Reps
+		session.Volume += set.Weight * float64(set.Reps)
+		session.TopWeight = max(session.TopWeight, set.Weight)
+		session.BestOneRepMax = max(session.BestOneRepMax, formula.Estimate(set.Weight, set.Reps))
+	}
+
+	return sessions, nil
+}
+
+func (s *RecordStore) getLoggedSets(ctx context.Context, userID uuid.UUID, exerciseID *uuid.UUID) ([]loggedSet, error) {
+	query := `
+		SELECT ws.exercise_id, e.name, w.id, w.started_at, ws.weight, ws.reps
+		FROM workout_sets ws
+		JOIN workouts w ON w.id = ws.workout_id
+		JOIN exercises e ON e.id = ws.exercise_id
+		WHERE w.user_id = $1
+			AND ($2::uuid IS NULL OR ws.exercise_id = $2)
+			AND ws.weight IS NOT NULL AND ws.reps > 0
+		ORDER BY w.started_at ASC, w.id, ws.position ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []loggedSet
+	for rows.Next() {
+		var set loggedSet
+		err := rows.Scan(
+			&set.ExerciseID,
+			&set.ExerciseName,
+			&set.WorkoutID,
+			&set.StartedAt,
+			&set.Weight,
+			&set.Reps,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, set)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+// computeRecords expects sets in chronological order so that ties keep the
+// earliest time a record was reached.
+func computeRecords(sets []loggedSet, formula OneRepMaxFormula) []ExerciseRecords {
+	type sessionKey struct {
+		exerciseID uuid.UUID
+		workoutID  uuid.UUID
+	}
+
+	byExercise := make(map[uuid.UUID]*ExerciseRecords)
+	repRecords := make(map[uuid.UUID]map[float64]SetRecord)
+	volumes := make(map[sessionKey]*SetRecord)
+	var order []uuid.UUID
+
+	for _, set := range sets {
+		rec, ok := byExercise[set.ExerciseID]
+		if !ok {
+			rec = &ExerciseRecords{ExerciseID: set.ExerciseID, ExerciseName: set.ExerciseName}
+			byExercise[set.ExerciseID] = rec
+			repRecords[set.ExerciseID] = make(map[float64]SetRecord)
+			order = append(order, set.ExerciseID)
+		}
+
+		record := SetRecord{
+			WorkoutID:  set.WorkoutID,
+			AchievedAt: set.StartedAt,
+			Weight:     set.Weight,
+			Reps:       set.Reps,
+		}
+
+		if set.Weight > rec.HeaviestWeight.Weight ||
+			(set.Weight == rec.HeaviestWeight.Weight && set.Reps > rec.HeaviestWeight.Reps) {
+			rec.HeaviestWeight = record
+			rec.HeaviestWeight.Value = set.Weight
+		}
+
+		if estimate := formula.Estimate(set.Weight, set.Reps); estimate > rec.BestOneRepMax.Value {
+			rec.BestOneRepMax = record
+			rec.BestOneRepMax.Value = estimate
+		}
+
+		if best, ok := repRecords[set.ExerciseID][set.Weight]; !ok || set.Reps > best.Reps {
+			record.Value = float64(set.Reps)
+			repRecords[set.ExerciseID][set.Weight] = record
+		}
+
+		key := sessionKey{set.ExerciseID, set.WorkoutID}
+		if _, ok := volumes[key]; !ok {
+			volumes[key] = &SetRecord{WorkoutID: set.WorkoutID, AchievedAt: set.StartedAt}
+		}
+		volumes[key].Reps += set.Reps
+		volumes[key].Value += set.Weight * float64(set.Reps)
+	}
+
+	for key, volume := range volumes {
+		rec := byExercise[key.exerciseID]
+		if volume.Value > rec.BestSessionVolume.Value ||
+			(volume.Value == rec.BestSessionVolume.Value && volume.AchievedAt < rec.BestSessionVolume.AchievedAt) {
+			rec.BestSessionVolume = *volume
+		}
+	}
+
+	records := make([]ExerciseRecords, 0, len(order))
+	for _, id := range order {
+		rec := byExercise[id]
+
+		rec.RepRecords = make([]SetRecord, 0, len(repRecords[id]))
+		for _, record := range repRecords[id] {
+			rec.RepRecords = append(rec.RepRecords, record)
+		}
+		sort.Slice(rec.RepRecords, func(i, j int) bool {
+			return rec.RepRecords[i].Weight > rec.RepRecords[j].Weight
+		})
+
+		records = append(records, *rec)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ExerciseName < records[j].ExerciseName
+	})
+
+	return records
+}
+
+const (
+	RecordHeaviestWeight = "heaviest_weight"
+	RecordOneRepMax      = "one_rep_max"
+	RecordSessionVolume  = "session_volume"
+)
+
+type PersonalRecord struct {
+	ExerciseID   uuid.UUID `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	Kind         string    `json:"kind"`
+	Weight       float64   `json:"weight,omitempty"`
+	Reps         int       `json:"reps,omitempty"`
+	Value        float64   `json:"value"`
+	Previous     float64   `json:"previous"`
+}
+
+// GetWorkoutRecords returns the records the workout beat compared to everything
+// logged before it. Exercises logged for the first time never count as records.
+func (s *RecordStore) GetWorkoutRecords(ctx context.Context, userID, workoutID uuid.UUID, formula OneRepMaxFormula) ([]PersonalRecord, error) {
+	sets, err := s.getLoggedSets(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var before, current []loggedSet
+	for _, set := range sets {
+		if set.WorkoutID == workoutID {
+			current = append(current, set)
+			continue
+		}
+
+		// sets are ordered chronologically, so anything after the workout is ignored
+		if len(current) == 0 {
+			before = append(before, set)
+		}
+	}
+
+	previous := make(map[uuid.UUID]ExerciseRecords)
+	for _, rec := range computeRecords(before, formula) {
+		previous[rec.ExerciseID] = rec
+	}
+
+	records := []PersonalRecord{}
+	for _, rec := range computeRecords(current, formula) {
+		prev, ok := previous[rec.ExerciseID]
+		if !ok {
+			continue
+		}
+
+		if rec.HeaviestWeight.Value > prev.HeaviestWeight.Value {
+			records = append(records, PersonalRecord{
+				ExerciseID:   rec.ExerciseID,
+				ExerciseName: rec.ExerciseName,
+				Kind:         RecordHeaviestWeight,
+				Weight:       rec.HeaviestWeight.Weight,
+				Reps:         rec.HeaviestWeight.Reps,
+				Value:        rec.HeaviestWeight.Value,
+				Previous:     prev.HeaviestWeight.Value,
+			})
+		}
+
+		if rec.BestOneRepMax.Value > prev.BestOneRepMax.Value {
+			records = append(records, PersonalRecord{
+				ExerciseID:   rec.ExerciseID,
+				ExerciseName: rec.ExerciseName,
+				Kind:         RecordOneRepMax,
+				Weight:       rec.BestOneRepMax.Weight,
+				Reps:         rec.BestOneRepMax.Reps,
+				Value:        rec.BestOneRepMax.Value,
+				Previous:     prev.BestOneRepMax.Value,
+			})
+		}
+
+		if rec.BestSessionVolume.Value > prev.BestSessionVolume.Value {
+			records = append(records, PersonalRecord{
+				ExerciseID:   rec.ExerciseID,
+				ExerciseName: rec.ExerciseName,
+				Kind:         RecordSessionVolume,
+				Value:        rec.BestSessionVolume.Value,
+				Previous:     prev.BestSessionVolume.Value,
+			})
+		}
+	}
+
+	return records, nil
+}
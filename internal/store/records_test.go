@@ -0,0 +1,55 @@
+package store
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOneRepMaxFormulaEstimate(t *testing.T) {
+	tests := []struct {
+		name    string
+		formula OneRepMaxFormula
+		weight  float64
+		reps    int
+		want    float64
+	}{
+		{"no reps", Epley, 100, 0, 0},
+		{"single is the weight", Epley, 100, 1, 100},
+		{"single is the weight brzycki", Brzycki, 100, 1, 100},
+		{"epley", Epley, 100, 5, 100 * (1 + 5.0/30)},
+		{"epley high reps", Epley, 50, 40, 50 * (1 + 40.0/30)},
+		{"brzycki", Brzycki, 100, 5, 100 * 36.0 / 32},
+		{"brzycki at its limit", Brzycki, 100, 10, 100 * 36.0 / 27},
+		{"brzycki past its limit uses epley", Brzycki, 100, 11, 100 * (1 + 11.0/30)},
+		{"brzycki long set uses epley", Brzycki, 20, 36, 20 * (1 + 36.0/30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.formula.Estimate(tt.weight, tt.reps)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Estimate(%v, %d) = %v, want %v", tt.weight, tt.reps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOneRepMaxFormula(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    OneRepMaxFormula
+		wantErr error
+	}{
+		{"", Epley, nil},
+		{"epley", Epley, nil},
+		{"brzycki", Brzycki, nil},
+		{"lombardi", "", ErrUnknownFormula},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOneRepMaxFormula(tt.name)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("ParseOneRepMaxFormula(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
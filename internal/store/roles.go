@@ -1,8 +1,21 @@
 package store
 
+import "github.com/google/uuid"
+
+// Role levels as seeded by the roles migration, a higher level includes every
+// permission of the levels below it.
+const (
+	RoleLevelUser       = 1
+	RoleLevelSubscriber = 2
+	RoleLevelAdmin      = 3
+)
+
+// DefaultRole is given to every newly registered user.
+const DefaultRole = "user"
+
 type Role struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Level       int    `json:"level"`
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Level       int       `json:"level"`
 }
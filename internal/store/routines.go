@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Routine struct {
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Name      string            `json:"name"`
+	Notes     string            `json:"notes"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+	Exercises []RoutineExercise `json:"exercises,omitempty"`
+}
+
+type RoutineExercise struct {
+	ID           uuid.UUID `json:"id"`
+	RoutineID    uuid.UUID `json:"routine_id"`
+	ExerciseID   uuid.UUID `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name,omitempty"`
+	Position     int       `json:"position"`
+	TargetSets   int       `json:"target_sets"`
+	TargetReps   int       `json:"target_reps"`
+	TargetWeight *float64  `json:"target_weight"`
+}
+
+// Sets expands the routine into the concrete sets of a workout, passing every
+// target weight through adjust so callers can apply progression.
+func (r *Routine) Sets(adjust func(float64) float64) []WorkoutSet {
+	sets := []WorkoutSet{}
+	for _, exercise := range r.Exercises {
+		for i := 0; i < exercise.TargetSets; i++ {
+			set := WorkoutSet{
+				ExerciseID:   exercise.ExerciseID,
+				ExerciseName: exercise.ExerciseName,
+				Position:     len(sets),
+				Reps:         &exercise.TargetReps,
+			}
+
+			if exercise.TargetWeight != nil {
+				weight := adjust(*exercise.TargetWeight)
+				set.Weight = &weight
+			}
+
+			sets = append(sets, set)
+		}
+	}
+
+	return sets
+}
+
+type RoutineStore struct {
+	db *sql.DB
+}
+
+func (s *RoutineStore) Create(ctx context.Context, routine *Routine) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, routine); err != nil {
+			return err
+		}
+
+		return s.createExercises(ctx, tx, routine)
+	})
+}
+
+func (s *RoutineStore) create(ctx context.Context, tx *sql.Tx, routine *Routine) error {
+	query := `
+		INSERT INTO routines (user_id, name, notes)
+		VALUES ($1, $2, $3) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		routine.UserID,
+		routine.Name,
+		routine.Notes,
+	).Scan(
+		&routine.ID,
+		&routine.CreatedAt,
+		&routine.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RoutineStore) createExercises(ctx context.Context, tx *sql.Tx, routine *Routine) error {
+	query := `
+		INSERT INTO routine_exercises (routine_id, exercise_id, position, target_sets, target_reps, target_weight)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := range routine.Exercises {
+		exercise := &routine.Exercises[i]
+		exercise.RoutineID = routine.ID
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			exercise.RoutineID,
+			exercise.ExerciseID,
+			exercise.Position,
+			exercise.TargetSets,
+			exercise.TargetReps,
+			exercise.TargetWeight,
+		).Scan(
+			&exercise.ID,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrUnknownExercise
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RoutineStore) GetByID(ctx context.Context, id uuid.UUID) (*Routine, error) {
+	query := `
+		SELECT id, user_id, name, notes, created_at, updated_at
+		FROM routines
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var routine Routine
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&routine.ID,
+		&routine.UserID,
+		&routine.Name,
+		&routine.Notes,
+		&routine.CreatedAt,
+		&routine.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	exercises, err := s.getExercises(ctx, routine.ID)
+	if err != nil {
+		return nil, err
+	}
+	routine.Exercises = exercises
+
+	return &routine, nil
+}
+
+func (s *RoutineStore) getExercises(ctx context.Context, routineID uuid.UUID) ([]RoutineExercise, error) {
+	query := `
+		SELECT re.id, re.routine_id, re.exercise_id, e.name, re.position, re.target_sets, re.target_reps, re.target_weight
+		FROM routine_exercises re
+		JOIN exercises e ON e.id = re.exercise_id
+		WHERE re.routine_id = $1
+		ORDER BY re.position ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, routineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []RoutineExercise{}
+	for rows.Next() {
+		var exercise RoutineExercise
+		err := rows.Scan(
+			&exercise.ID,
+			&exercise.RoutineID,
+			&exercise.ExerciseID,
+			&exercise.ExerciseName,
+			&exercise.Position,
+			&exercise.TargetSets,
+			&exercise.TargetReps,
+			&exercise.TargetWeight,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		exercises = append(exercises, exercise)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exercises, nil
+}
+
+func (s *RoutineStore) GetByUser(ctx context.Context, userID uuid.UUID) ([]Routine, error) {
+	query := `
+		SELECT id, user_id, name, notes, created_at, updated_at
+		FROM routines
+		WHERE user_id = $1
+		ORDER BY name ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routines := []Routine{}
+	for rows.Next() {
+		var routine Routine
+		err := rows.Scan(
+			&routine.ID,
+			&routine.UserID,
+			&routine.Name,
+			&routine.Notes,
+			&routine.CreatedAt,
+			&routine.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		routines = append(routines, routine)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return routines, nil
+}
+
+func (s *RoutineStore) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM routines
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -22,11 +22,35 @@ type Storage struct {
 		Create(context.Context, *sql.Tx, *User) error
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Activate(ctx context.Context, token string) error
+		CreatePasswordReset(ctx context.Context, token string, exp time.Duration, userID uuid.UUID) error
+		ResetPassword(ctx context.Context, token string, password string) (uuid.UUID, error)
 		Delete(ctx context.Context, userID uuid.UUID) error
+		GetPrivacy(context.Context, uuid.UUID) (*PrivacySettings, error)
+		UpdatePrivacy(context.Context, uuid.UUID, *PrivacySettings) error
 	}
 	Foods interface {
 		Create(context.Context, *Food) error
 		GetByID(context.Context, uuid.UUID) (*Food, error)
+		GetByBarcode(ctx context.Context, barcode string, viewerID uuid.UUID) (*Food, error)
+		Search(context.Context, PaginatedFoodQuery) (*FoodPage, error)
+		GetByUser(context.Context, uuid.UUID, PaginatedQuery) (*FoodPage, error)
+		GetFavourites(context.Context, uuid.UUID, PaginatedQuery) (*FoodPage, error)
+		Favourite(ctx context.Context, userID, foodID uuid.UUID) error
+		Unfavourite(ctx context.Context, userID, foodID uuid.UUID) error
+		GetPending(context.Context, PaginatedQuery) (*FoodPage, error)
+		Approve(ctx context.Context, food *Food, moderatorID uuid.UUID) error
+		Reject(ctx context.Context, food *Food, moderatorID uuid.UUID, reason string) error
+		ModeratorEdit(ctx context.Context, before, after *Food, moderatorID uuid.UUID) error
+		GetModerationHistory(context.Context, uuid.UUID) ([]FoodModerationAction, error)
+		Update(ctx context.Context, food *Food, editorID uuid.UUID) error
+		GetRevisions(context.Context, uuid.UUID) ([]FoodRevision, error)
+		GetPortions(context.Context, uuid.UUID) ([]FoodPortion, error)
+		CreatePortion(context.Context, *FoodPortion) error
+		DeletePortion(ctx context.Context, foodID, portionID uuid.UUID) error
+		DetectDuplicates(context.Context) (int, error)
+		GetDuplicateClusters(context.Context) ([]DuplicateCluster, error)
+		Merge(ctx context.Context, fromID, intoID, moderatorID uuid.UUID) error
+		Import(ctx context.Context, source, externalID string, food *Food) (ImportResult, error)
 	}
 	Meals interface {
 		CreateMeal(context.Context, *Meal) error
@@ -34,8 +58,84 @@ type Storage struct {
 		GetMealByID(context.Context, uuid.UUID) (*Meal, error)
 		CreateMealEntry(context.Context, *MealEntry) error
 		GetMealEntryByID(context.Context, uuid.UUID) (*MealEntry, error)
+		GetMealEntryWithMeal(context.Context, uuid.UUID) (*MealEntryWithMeal, error)
 		UpdateMealEntry(context.Context, *MealEntry) error
 		DeleteMealEntry(context.Context, uuid.UUID) error
+		SetMealShared(ctx context.Context, mealID uuid.UUID, shared bool) error
+		GetDiary(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]DiaryDay, error)
+		GetMealEntries(context.Context, uuid.UUID) ([]MealEntry, error)
+		CopyMeals(ctx context.Context, userID uuid.UUID, from, to time.Time, mealName, toMealName string) ([]MealEntry, error)
+		GetFoodSuggestions(context.Context, uuid.UUID, FoodSuggestionQuery) (*FoodSuggestions, error)
+	}
+	MealTemplates interface {
+		Create(context.Context, *MealTemplate) error
+		GetByID(context.Context, uuid.UUID) (*MealTemplate, error)
+		GetByUser(context.Context, uuid.UUID) ([]MealTemplate, error)
+		Delete(context.Context, uuid.UUID) error
+		Apply(ctx context.Context, template *MealTemplate, meal *Meal, consumedAt string) ([]MealEntry, error)
+	}
+	Exercises interface {
+		Create(context.Context, *Exercise) error
+		GetByID(context.Context, uuid.UUID) (*Exercise, error)
+		List(context.Context, ExerciseFilter) ([]Exercise, error)
+	}
+	Workouts interface {
+		Create(context.Context, *Workout) error
+		GetByID(context.Context, uuid.UUID) (*Workout, error)
+		GetByUser(context.Context, uuid.UUID, PaginatedQuery) (*WorkoutPage, error)
+		Update(context.Context, *Workout) error
+		Delete(context.Context, uuid.UUID) error
+	}
+	Routines interface {
+		Create(context.Context, *Routine) error
+		GetByID(context.Context, uuid.UUID) (*Routine, error)
+		GetByUser(context.Context, uuid.UUID) ([]Routine, error)
+		Delete(context.Context, uuid.UUID) error
+	}
+	Programs interface {
+		Create(context.Context, *Program) error
+		GetByID(context.Context, uuid.UUID) (*Program, error)
+		Enroll(context.Context, *ProgramEnrollment) error
+		GetEnrollment(ctx context.Context, userID, programID uuid.UUID) (*ProgramEnrollment, error)
+		StartWorkout(context.Context, *Program, *ProgramEnrollment, *Workout) error
+	}
+	Records interface {
+		GetByUser(context.Context, uuid.UUID, OneRepMaxFormula) ([]ExerciseRecords, error)
+		GetExerciseHistory(ctx context.Context, userID, exerciseID uuid.UUID, formula OneRepMaxFormula) ([]ExerciseSession, error)
+		GetWorkoutRecords(ctx context.Context, userID, workoutID uuid.UUID, formula OneRepMaxFormula) ([]PersonalRecord, error)
+	}
+	Activities interface {
+		Create(context.Context, *Activity) error
+		DeleteByReference(context.Context, uuid.UUID) error
+		GetUserFeed(context.Context, uuid.UUID, PaginatedFeedQuery) (*FeedPage, error)
+	}
+	Tokens interface {
+		CreateRefreshToken(context.Context, *RefreshToken) error
+		RotateRefreshToken(ctx context.Context, hash string, next *RefreshToken) error
+		RevokeRefreshToken(ctx context.Context, hash string) error
+		RevokeAccessToken(ctx context.Context, jti uuid.UUID, expiry time.Time) error
+		IsAccessTokenRevoked(context.Context, uuid.UUID) (bool, error)
+	}
+	Nutrition interface {
+		GetProfile(context.Context, uuid.UUID) (*NutritionProfile, error)
+		UpsertProfile(context.Context, *NutritionProfile) error
+		CreateTargets(context.Context, *NutritionTargets) error
+		GetTargetsHistory(context.Context, uuid.UUID) ([]NutritionTargets, error)
+		UpdateBodyComposition(ctx context.Context, userID uuid.UUID, weightKg, bodyFatPercent *float64) error
+	}
+	Recipes interface {
+		Create(context.Context, *Recipe) error
+		GetByID(context.Context, uuid.UUID) (*Recipe, error)
+		GetByUser(context.Context, uuid.UUID) ([]Recipe, error)
+		Update(context.Context, *Recipe) error
+		Delete(context.Context, uuid.UUID) error
+	}
+	Measurements interface {
+		Create(context.Context, *Measurement) error
+		GetByID(context.Context, uuid.UUID) (*Measurement, error)
+		GetByUser(context.Context, uuid.UUID, PaginatedMeasurementQuery) (*MeasurementPage, error)
+		Delete(context.Context, uuid.UUID) error
+		GetTrend(ctx context.Context, userID uuid.UUID, measurementType string, from time.Time) (*MeasurementTrend, error)
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID uuid.UUID) error
@@ -45,10 +145,21 @@ type Storage struct {
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Users:     &UserStore{db},
-		Foods:     &FoodStore{db},
-		Meals:     &MealStore{db},
-		Followers: &FollowerStore{db},
+		Users:         &UserStore{db},
+		Foods:         &FoodStore{db},
+		Meals:         &MealStore{db},
+		Exercises:     &ExerciseStore{db},
+		Workouts:      &WorkoutStore{db},
+		Routines:      &RoutineStore{db},
+		Programs:      &ProgramStore{db},
+		Records:       &RecordStore{db},
+		Tokens:        &TokenStore{db},
+		Followers:     &FollowerStore{db},
+		Activities:    &ActivityStore{db},
+		Nutrition:     &NutritionStore{db},
+		Measurements:  &MeasurementStore{db},
+		Recipes:       &RecipeStore{db},
+		MealTemplates: &MealTemplateStore{db},
 	}
 }
 
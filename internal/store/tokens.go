@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTokenExpired = errors.New("token has expired")
+	ErrTokenReused  = errors.New("refresh token reuse detected")
+)
+
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	Expiry     time.Time  `json:"expiry"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy *uuid.UUID `json:"replaced_by"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type TokenStore struct {
+	db *sql.DB
+}
+
+// CreateRefreshToken stores a refresh token. Tokens without a family start a
+// new one, which is what every rotation of it will share.
+func (s *TokenStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.createRefreshToken(ctx, tx, token)
+	})
+}
+
+func (s *TokenStore) createRefreshToken(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expiry)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+
+	if token.FamilyID == uuid.Nil {
+		token.FamilyID = uuid.New()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.Expiry,
+	).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RotateRefreshToken swaps the token with the given hash for next. Presenting a
+// token that was already rotated or revoked means it leaked, so the whole
+// family is revoked and ErrTokenReused is returned.
+func (s *TokenStore) RotateRefreshToken(ctx context.Context, hash string, next *RefreshToken) error {
+	reused := false
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getRefreshTokenForUpdate(ctx, tx, hash)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return s.revokeRefreshFamily(ctx, tx, current.FamilyID)
+		}
+
+		if time.Now().After(current.Expiry) {
+			return ErrTokenExpired
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		if err := s.createRefreshToken(ctx, tx, next); err != nil {
+			return err
+		}
+
+		return s.markRefreshTokenReplaced(ctx, tx, current.ID, next.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrTokenReused
+	}
+
+	return nil
+}
+
+func (s *TokenStore) getRefreshTokenForUpdate(ctx context.Context, tx *sql.Tx, hash string) (*RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expiry, revoked_at, replaced_by, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.Expiry,
+		&token.RevokedAt,
+		&token.ReplacedBy,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+func (s *TokenStore) markRefreshTokenReplaced(ctx context.Context, tx *sql.Tx, id, replacedBy uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, replacedBy, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TokenStore) revokeRefreshFamily(ctx context.Context, tx *sql.Tx, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TokenStore) revokeUserRefreshTokens(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeRefreshToken revokes the family of the token with the given hash, ending
+// that session on every rotation of it.
+func (s *TokenStore) RevokeRefreshToken(ctx context.Context, hash string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		token, err := s.getRefreshTokenForUpdate(ctx, tx, hash)
+		if err != nil {
+			return err
+		}
+
+		return s.revokeRefreshFamily(ctx, tx, token.FamilyID)
+	})
+}
+
+// RevokeAccessToken adds the token's jti to the denylist until it expires.
+func (s *TokenStore) RevokeAccessToken(ctx context.Context, jti uuid.UUID, expiry time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expiry) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, expiry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TokenStore) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
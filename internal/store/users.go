@@ -29,6 +29,15 @@ type User struct {
 	Bio       string    `json:"bio"`
 	CreatedAt string    `json:"created_at"`
 	IsActive  bool      `json:"is_active"`
+	Role      Role      `json:"role"`
+	// PasswordChangedAt is when the password was last reset, access tokens
+	// issued before it are rejected.
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+}
+
+type PrivacySettings struct {
+	ShareWorkouts bool `json:"share_workouts"`
+	ShareRecords  bool `json:"share_records"`
 }
 
 type password struct {
@@ -60,11 +69,15 @@ type UserStore struct {
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `
-		INSERT INTO users (first_name, last_name, email, username, password) VALUES 
-		($1, $2, $3, $4, $5)
-		RETURNING id, created_at
+		INSERT INTO users (first_name, last_name, email, username, password, role_id) VALUES 
+		($1, $2, $3, $4, $5, (SELECT id FROM roles WHERE name = $6))
+		RETURNING id, created_at, role_id
 	`
 
+	if user.Role.Name == "" {
+		user.Role.Name = DefaultRole
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
@@ -76,9 +89,11 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		user.Email,
 		user.Username,
 		user.Password.hash,
+		user.Role.Name,
 	).Scan(
 		&user.ID,
 		&user.CreatedAt,
+		&user.Role.ID,
 	)
 	if err != nil {
 		switch {
@@ -96,9 +111,11 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) GetByID(ctx context.Context, userID uuid.UUID) (*User, error) {
 	query := `
-		SELECT id, username, first_name, last_name, email, password, bio, created_at
-		FROM users
-		WHERE id = $1 AND is_active = true
+		SELECT u.id, u.username, u.first_name, u.last_name, u.email, u.password, u.bio, u.created_at, u.password_changed_at,
+			r.id, r.name, COALESCE(r.description, ''), r.level
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = $1 AND u.is_active = true
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -118,6 +135,11 @@ func (s *UserStore) GetByID(ctx context.Context, userID uuid.UUID) (*User, error
 		&user.Password.hash,
 		&user.Bio,
 		&user.CreatedAt,
+		&user.PasswordChangedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Description,
+		&user.Role.Level,
 	)
 	if err != nil {
 		switch err {
@@ -264,6 +286,134 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+func (s *UserStore) CreatePasswordReset(ctx context.Context, token string, resetExp time.Duration, userID uuid.UUID) error {
+	query := `
+		INSERT INTO password_resets (token, user_id, expiry) VALUES ($1, $2, $3)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		token,
+		userID,
+		time.Now().Add(resetExp),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password for the owner of the reset token and
+// returns their ID. Every reset token of the user is removed so the link only
+// works once, all of their refresh tokens are revoked and the change time is
+// recorded so that access tokens issued before it stop working.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, password string) (uuid.UUID, error) {
+	var userID uuid.UUID
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// 1. find the user that this token belongs to
+		user, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		// 2. set the new password
+		if err := user.Password.Set(password); err != nil {
+			return err
+		}
+
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		// 3. clean the reset tokens
+		if err := s.deletePasswordResets(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		// 4. end every existing session
+		tokens := &TokenStore{s.db}
+		if err := tokens.revokeUserRefreshTokens(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		userID = user.ID
+
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+func (s *UserStore) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email
+		FROM users u
+		JOIN password_resets pr ON u.id = pr.user_id
+		WHERE pr.token = $1 AND pr.expiry > $2
+		FOR UPDATE OF pr
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `UPDATE users SET password = $1, password_changed_at = date_trunc('second', NOW()) WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	query := `DELETE FROM password_resets WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStore) Delete(ctx context.Context, userID uuid.UUID) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.delete(ctx, tx, userID); err != nil {
@@ -294,9 +444,11 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userID uuid.UUID) er
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, first_name, last_name, email, password, bio, created_at
-		FROM users
-		WHERE email = $1 AND is_active = true
+		SELECT u.id, u.username, u.first_name, u.last_name, u.email, u.password, u.bio, u.created_at, u.password_changed_at,
+			r.id, r.name, COALESCE(r.description, ''), r.level
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.email = $1 AND u.is_active = true
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -312,6 +464,11 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Password.hash,
 		&user.Bio,
 		&user.CreatedAt,
+		&user.PasswordChangedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Description,
+		&user.Role.Level,
 	)
 	if err != nil {
 		switch err {
@@ -324,3 +481,40 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	return user, nil
 }
+
+func (s *UserStore) GetPrivacy(ctx context.Context, userID uuid.UUID) (*PrivacySettings, error) {
+	query := `SELECT share_workouts, share_records FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	settings := &PrivacySettings{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&settings.ShareWorkouts,
+		&settings.ShareRecords,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return settings, nil
+}
+
+func (s *UserStore) UpdatePrivacy(ctx context.Context, userID uuid.UUID, settings *PrivacySettings) error {
+	query := `UPDATE users SET share_workouts = $1, share_records = $2 WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, settings.ShareWorkouts, settings.ShareRecords, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrUnknownExercise = errors.New("exercise not found")
+
+type Workout struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Name      string       `json:"name"`
+	Notes     string       `json:"notes"`
+	StartedAt string       `json:"started_at"`
+	EndedAt   *string      `json:"ended_at"`
+	CreatedAt string       `json:"created_at"`
+	UpdatedAt string       `json:"updated_at"`
+	Sets      []WorkoutSet `json:"sets,omitempty"`
+}
+
+type WorkoutSet struct {
+	ID              uuid.UUID `json:"id"`
+	WorkoutID       uuid.UUID `json:"workout_id"`
+	ExerciseID      uuid.UUID `json:"exercise_id"`
+	ExerciseName    string    `json:"exercise_name,omitempty"`
+	Position        int       `json:"position"`
+	Reps            *int      `json:"reps"`
+	Weight          *float64  `json:"weight"`
+	RPE             *float64  `json:"rpe"`
+	DurationSeconds *int      `json:"duration_seconds"`
+	DistanceMeters  *float64  `json:"distance_meters"`
+	CreatedAt       string    `json:"created_at"`
+	UpdatedAt       string    `json:"updated_at"`
+}
+
+type WorkoutPage struct {
+	Workouts   []Workout `json:"workouts"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type workoutCursor struct {
+	StartedAt time.Time `json:"s"`
+	ID        uuid.UUID `json:"i"`
+}
+
+type WorkoutStore struct {
+	db *sql.DB
+}
+
+func (s *WorkoutStore) Create(ctx context.Context, workout *Workout) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, workout); err != nil {
+			return err
+		}
+
+		return s.createSets(ctx, tx, workout)
+	})
+}
+
+func (s *WorkoutStore) create(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `
+		INSERT INTO workouts (user_id, name, notes, started_at, ended_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		workout.UserID,
+		workout.Name,
+		workout.Notes,
+		workout.StartedAt,
+		workout.EndedAt,
+	).Scan(
+		&workout.ID,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *WorkoutStore) createSets(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `
+		INSERT INTO workout_sets (workout_id, exercise_id, position, reps, weight, rpe, duration_seconds, distance_meters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for i := range workout.Sets {
+		set := &workout.Sets[i]
+		set.WorkoutID = workout.ID
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			set.WorkoutID,
+			set.ExerciseID,
+			set.Position,
+			set.Reps,
+			set.Weight,
+			set.RPE,
+			set.DurationSeconds,
+			set.DistanceMeters,
+		).Scan(
+			&set.ID,
+			&set.CreatedAt,
+			&set.UpdatedAt,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrUnknownExercise
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WorkoutStore) GetByID(ctx context.Context, id uuid.UUID) (*Workout, error) {
+	query := `
+		SELECT id, user_id, name, notes, started_at, ended_at, created_at, updated_at
+		FROM workouts
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var workout Workout
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&workout.ID,
+		&workout.UserID,
+		&workout.Name,
+		&workout.Notes,
+		&workout.StartedAt,
+		&workout.EndedAt,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	sets, err := s.getSets(ctx, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+	workout.Sets = sets
+
+	return &workout, nil
+}
+
+func (s *WorkoutStore) getSets(ctx context.Context, workoutID uuid.UUID) ([]WorkoutSet, error) {
+	query := `
+		SELECT ws.id, ws.workout_id, ws.exercise_id, e.name, ws.position, ws.reps, ws.weight, ws.rpe,
+			ws.duration_seconds, ws.distance_meters, ws.created_at, ws.updated_at
+		FROM workout_sets ws
+		JOIN exercises e ON e.id = ws.exercise_id
+		WHERE ws.workout_id = $1
+		ORDER BY ws.position ASC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []WorkoutSet{}
+	for rows.Next() {
+		var set WorkoutSet
+		err := rows.Scan(
+			&set.ID,
+			&set.WorkoutID,
+			&set.ExerciseID,
+			&set.ExerciseName,
+			&set.Position,
+			&set.Reps,
+			&set.Weight,
+			&set.RPE,
+			&set.DurationSeconds,
+			&set.DistanceMeters,
+			&set.CreatedAt,
+			&set.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, set)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+func (s *WorkoutStore) GetByUser(ctx context.Context, userID uuid.UUID, fq PaginatedQuery) (*WorkoutPage, error) {
+	var cursor workoutCursor
+	var cursorStartedAt *time.Time
+	if fq.Cursor != "" {
+		if err := decodeCursor(fq.Cursor, &cursor); err != nil {
+			return nil, err
+		}
+
+		cursorStartedAt = &cursor.StartedAt
+	}
+
+	query := `
+		SELECT id, user_id, name, notes, started_at, ended_at, created_at, updated_at
+		FROM workouts
+		WHERE user_id = $1
+			AND ($2::timestamptz IS NULL OR (started_at, id) < ($2, $3))
+		ORDER BY started_at DESC, id DESC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// fetch one extra row to know whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query, userID, cursorStartedAt, cursor.ID, fq.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &WorkoutPage{Workouts: []Workout{}}
+	var last workoutCursor
+	for rows.Next() {
+		var workout Workout
+		var startedAt time.Time
+		err := rows.Scan(
+			&workout.ID,
+			&workout.UserID,
+			&workout.Name,
+			&workout.Notes,
+			&startedAt,
+			&workout.EndedAt,
+			&workout.CreatedAt,
+			&workout.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Workouts) == fq.Limit {
+			next, err := encodeCursor(last)
+			if err != nil {
+				return nil, err
+			}
+
+			page.NextCursor = next
+			break
+		}
+
+		workout.StartedAt = startedAt.Format(time.RFC3339Nano)
+		page.Workouts = append(page.Workouts, workout)
+		last = workoutCursor{StartedAt: startedAt, ID: workout.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Update saves the workout details and, when sets is non-nil, replaces the
+// logged sets with the given ones.
+func (s *WorkoutStore) Update(ctx context.Context, workout *Workout) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.update(ctx, tx, workout); err != nil {
+			return err
+		}
+
+		if workout.Sets == nil {
+			return nil
+		}
+
+		if err := s.deleteSets(ctx, tx, workout.ID); err != nil {
+			return err
+		}
+
+		return s.createSets(ctx, tx, workout)
+	})
+}
+
+func (s *WorkoutStore) update(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `
+		UPDATE workouts
+		SET name = $1, notes = $2, started_at = $3, ended_at = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		workout.Name,
+		workout.Notes,
+		workout.StartedAt,
+		workout.EndedAt,
+		workout.ID,
+	).Scan(
+		&workout.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WorkoutStore) deleteSets(ctx context.Context, tx *sql.Tx, workoutID uuid.UUID) error {
+	query := `DELETE FROM workout_sets WHERE workout_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, workoutID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *WorkoutStore) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM workouts
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package units
+
+import (
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// backfillMigration converts the amounts saved before units were checked with
+// its own copy of the unit table, which has to stay in line with known.
+const backfillMigration = "../../cmd/migrate/migrations/000046_add_food_portions.up.sql"
+
+var backfillUnitRow = regexp.MustCompile(`\('([^']+)', '(mass|volume)', ([0-9.]+)\)`)
+
+func readBackfillUnits(t *testing.T) map[string]Unit {
+	t.Helper()
+
+	sql, err := os.ReadFile(backfillMigration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dimensions := map[string]Dimension{"mass": Mass, "volume": Volume}
+
+	backfill := make(map[string]Unit)
+	for _, row := range backfillUnitRow.FindAllStringSubmatch(string(sql), -1) {
+		factor, err := strconv.ParseFloat(row[3], 64)
+		if err != nil {
+			t.Fatalf("unit %q: %v", row[1], err)
+		}
+
+		if _, ok := backfill[row[1]]; ok {
+			t.Errorf("unit %q is listed twice", row[1])
+		}
+
+		backfill[row[1]] = Unit{Dimension: dimensions[row[2]], Factor: factor}
+	}
+
+	return backfill
+}
+
+func TestBackfillUnitsMatchKnown(t *testing.T) {
+	backfill := readBackfillUnits(t)
+
+	for name, unit := range known {
+		t.Run(name, func(t *testing.T) {
+			got, ok := backfill[name]
+			if !ok {
+				t.Fatalf("missing from the backfill")
+			}
+
+			if got.Dimension != unit.Dimension || got.Factor != unit.Factor {
+				t.Errorf("got %v %v, want %v %v", got.Dimension, got.Factor, unit.Dimension, unit.Factor)
+			}
+		})
+	}
+
+	for name := range backfill {
+		if _, ok := known[name]; !ok {
+			t.Errorf("backfill lists %q, which isn't a known unit", name)
+		}
+	}
+}
+
+func TestBackfillConversions(t *testing.T) {
+	backfill := readBackfillUnits(t)
+
+	tests := []struct {
+		name        string
+		amount      float64
+		unit        string
+		servingUnit string
+		want        float64
+		ok          bool
+	}{
+		{name: "kilograms to grams", amount: 0.5, unit: "kg", servingUnit: "g", want: 500, ok: true},
+		{name: "ounces to grams", amount: 2, unit: "oz", servingUnit: "g", want: 56.699046, ok: true},
+		{name: "cups to millilitres", amount: 1, unit: "cups", servingUnit: "ml", want: 236.5882365, ok: true},
+		{name: "spacing and case", amount: 2, unit: " Fl  OZ ", servingUnit: "ml", want: 59.147059, ok: true},
+		{name: "mass to volume", amount: 100, unit: "g", servingUnit: "ml", ok: false},
+		{name: "unknown unit", amount: 2, unit: "slice", servingUnit: "g", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// what the backfill does: normalise both units, then scale by the
+			// ratio of their factors when they measure the same thing
+			from, fromOK := backfill[Normalize(tt.unit)]
+			to, toOK := backfill[Normalize(tt.servingUnit)]
+			ok := fromOK && toOK && from.Dimension == to.Dimension
+			if ok != tt.ok {
+				t.Fatalf("converts = %v, want %v", ok, tt.ok)
+			}
+
+			want, err := Measure{ServingUnit: tt.servingUnit}.ServingUnits(tt.amount, tt.unit)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Measure disagrees: %v", err)
+			}
+
+			if !ok {
+				return
+			}
+
+			got := tt.amount * from.Factor / to.Factor
+			if math.Abs(got-tt.want) > 1e-6 || math.Abs(got-want) > 1e-9 {
+				t.Errorf("got %v, want %v (Measure %v)", got, tt.want, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package units
+
+import "errors"
+
+var ErrUnsupportedUnit = errors.New("unit can't be converted to the food's serving unit")
+
+// Serving is the unit for a number of the food's servings, whatever its
+// serving size is.
+const Serving = "serving"
+
+// Portion is a household measure of a particular food, e.g. 1 slice = 28 g.
+type Portion struct {
+	Name   string
+	Amount float64
+	Unit   string
+}
+
+// Measure holds what's needed to convert amounts of one food into its serving
+// unit. Density is in g/ml and zero when it isn't known, without it amounts
+// can't be converted between mass and volume.
+type Measure struct {
+	ServingUnit string
+	ServingSize float64
+	Density     float64
+	Portions    []Portion
+}
+
+// ServingUnits converts an amount in the given unit to the food's serving
+// unit, which is what its nutrition is scaled by.
+func (m Measure) ServingUnits(amount float64, unit string) (float64, error) {
+	name := Normalize(unit)
+
+	switch name {
+	case Normalize(m.ServingUnit):
+		return amount, nil
+	case Serving:
+		return amount * m.ServingSize, nil
+	}
+
+	for _, portion := range m.Portions {
+		if Normalize(portion.Name) == name {
+			// portions are defined in units of the food, not other portions
+			return m.withoutPortions().ServingUnits(amount*portion.Amount, portion.Unit)
+		}
+	}
+
+	from, ok := Lookup(name)
+	if !ok {
+		return 0, ErrUnsupportedUnit
+	}
+
+	to, ok := Lookup(m.ServingUnit)
+	if !ok {
+		return 0, ErrUnsupportedUnit
+	}
+
+	base := amount * from.Factor
+	switch {
+	case from.Dimension == to.Dimension:
+	case m.Density <= 0:
+		return 0, ErrUnsupportedUnit
+	case from.Dimension == Volume:
+		base *= m.Density
+	default:
+		base /= m.Density
+	}
+
+	return base / to.Factor, nil
+}
+
+// Supports reports whether amounts in the unit can be converted.
+func (m Measure) Supports(unit string) bool {
+	_, err := m.ServingUnits(1, unit)
+	return err == nil
+}
+
+func (m Measure) withoutPortions() Measure {
+	m.Portions = nil
+	return m
+}
@@ -0,0 +1,86 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMeasureServingUnits(t *testing.T) {
+	grams := Measure{ServingUnit: "g", ServingSize: 100}
+	milk := Measure{ServingUnit: "ml", ServingSize: 250, Density: 1.03}
+	oil := Measure{ServingUnit: "g", ServingSize: 15, Density: 0.92}
+	bread := Measure{
+		ServingUnit: "g",
+		ServingSize: 50,
+		Portions: []Portion{
+			{Name: "slice", Amount: 28, Unit: "g"},
+			{Name: "loaf", Amount: 1, Unit: "kg"},
+			{Name: "crumbs", Amount: 2, Unit: "slice"},
+		},
+	}
+	soup := Measure{ServingUnit: "ml", ServingSize: 300, Portions: []Portion{{Name: "bowl", Amount: 1.5, Unit: "cup"}}}
+	eggs := Measure{ServingUnit: "egg", ServingSize: 1, Portions: []Portion{{Name: "dozen", Amount: 12, Unit: "egg"}}}
+
+	tests := []struct {
+		name    string
+		measure Measure
+		amount  float64
+		unit    string
+		want    float64
+		wantErr error
+	}{
+		{"serving unit", grams, 150, "g", 150, nil},
+		{"serving unit written differently", grams, 150, " G ", 150, nil},
+		{"alias of another unit", grams, 2, "kilograms", 2000, nil},
+		{"ounces", grams, 1, "oz", 28.349523125, nil},
+		{"milligrams", grams, 500, "mg", 0.5, nil},
+		{"serving keyword", grams, 1.5, "serving", 150, nil},
+		{"serving keyword of a volume", milk, 2, "Serving", 500, nil},
+		{"volume to volume", milk, 1, "cup", 236.5882365, nil},
+		{"fluid ounces", milk, 2, "fl  oz", 59.147059125, nil},
+		{"mass to volume with density", milk, 103, "g", 100, nil},
+		{"volume to mass with density", oil, 1, "tbsp", 14.78676478125 * 0.92, nil},
+		{"volume to mass without density", grams, 1, "cup", 0, ErrUnsupportedUnit},
+		{"mass to volume without density", soup, 100, "g", 0, ErrUnsupportedUnit},
+		{"portion", bread, 2, "slice", 56, nil},
+		{"portion in another unit", bread, 0.5, "loaf", 500, nil},
+		{"portion case", bread, 1, "Slice", 28, nil},
+		{"portions don't nest", bread, 1, "crumbs", 0, ErrUnsupportedUnit},
+		{"portion by volume", soup, 1, "bowl", 1.5 * 236.5882365, nil},
+		{"portion of a count", eggs, 2, "dozen", 24, nil},
+		{"count to mass", eggs, 100, "g", 0, ErrUnsupportedUnit},
+		{"unknown unit", grams, 1, "handful", 0, ErrUnsupportedUnit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.measure.ServingUnits(tt.amount, tt.unit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ServingUnits(%v, %q) error = %v, want %v", tt.amount, tt.unit, err, tt.wantErr)
+			}
+
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ServingUnits(%v, %q) = %v, want %v", tt.amount, tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeasureSupports(t *testing.T) {
+	measure := Measure{ServingUnit: "g", ServingSize: 30, Portions: []Portion{{Name: "scoop", Amount: 30, Unit: "g"}}}
+
+	for unit, want := range map[string]bool{
+		"g":       true,
+		"lb":      true,
+		"serving": true,
+		"scoop":   true,
+		"ml":      false,
+		"cup":     false,
+		"":        false,
+	} {
+		if got := measure.Supports(unit); got != want {
+			t.Errorf("Supports(%q) = %v, want %v", unit, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package units
+
+import "strings"
+
+type Dimension int
+
+const (
+	Mass Dimension = iota + 1
+	Volume
+)
+
+// Unit is a unit of mass or volume, Factor converts it to grams or
+// millilitres.
+type Unit struct {
+	Name      string
+	Dimension Dimension
+	Factor    float64
+}
+
+var (
+	gram       = Unit{"g", Mass, 1}
+	kilogram   = Unit{"kg", Mass, 1000}
+	milligram  = Unit{"mg", Mass, 0.001}
+	ounce      = Unit{"oz", Mass, 28.349523125}
+	pound      = Unit{"lb", Mass, 453.59237}
+	millilitre = Unit{"ml", Volume, 1}
+	litre      = Unit{"l", Volume, 1000}
+	teaspoon   = Unit{"tsp", Volume, 4.92892159375}
+	tablespoon = Unit{"tbsp", Volume, 14.78676478125}
+	fluidOunce = Unit{"fl oz", Volume, 29.5735295625}
+	cup        = Unit{"cup", Volume, 236.5882365}
+	pint       = Unit{"pint", Volume, 473.176473}
+)
+
+// known maps the names units are written as to the unit, volumes are US
+// customary measures.
+var known = map[string]Unit{
+	"g": gram, "gram": gram, "grams": gram, "gr": gram,
+	"kg": kilogram, "kilogram": kilogram, "kilograms": kilogram,
+	"mg": milligram, "milligram": milligram, "milligrams": milligram,
+	"oz": ounce, "ounce": ounce, "ounces": ounce,
+	"lb": pound, "lbs": pound, "pound": pound, "pounds": pound,
+	"ml": millilitre, "millilitre": millilitre, "millilitres": millilitre, "milliliter": millilitre, "milliliters": millilitre,
+	"l": litre, "litre": litre, "litres": litre, "liter": litre, "liters": litre,
+	"tsp": teaspoon, "teaspoon": teaspoon, "teaspoons": teaspoon,
+	"tbsp": tablespoon, "tablespoon": tablespoon, "tablespoons": tablespoon,
+	"fl oz": fluidOunce, "floz": fluidOunce, "fluid ounce": fluidOunce, "fluid ounces": fluidOunce,
+	"cup": cup, "cups": cup,
+	"pint": pint, "pints": pint,
+}
+
+// Normalize lower cases the name of a unit and collapses its spaces, the form
+// units and portions are compared in.
+func Normalize(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// Lookup finds the mass or volume unit with the given name.
+func Lookup(name string) (Unit, bool) {
+	unit, ok := known[Normalize(name)]
+	return unit, ok
+}